@@ -7,6 +7,7 @@ import (
 
 	_ "github.com/ironzhang/matrix/framework/modules/dashboard-module"
 	_ "github.com/ironzhang/matrix/framework/modules/debug-module"
+	_ "github.com/ironzhang/matrix/framework/modules/metrics-module"
 	_ "github.com/ironzhang/matrix/framework/modules/micro-module"
 )
 
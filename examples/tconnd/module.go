@@ -43,9 +43,10 @@ func (m *M) Fini() error {
 	return nil
 }
 
-func (m *M) Run(ctx context.Context) {
+func (m *M) Run(ctx context.Context) error {
 	log := tlog.Std().Sugar().With("module", m.Name(), "addr", m.ln.Addr().String())
 	log.Info("start")
-	serve(ctx, m.ln)
+	err := serve(ctx, m.ln)
 	log.Info("stop")
+	return err
 }
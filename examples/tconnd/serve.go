@@ -32,7 +32,7 @@ func init() {
 	expvar.Publish("stats", &g)
 }
 
-func serve(ctx context.Context, ln net.Listener) {
+func serve(ctx context.Context, ln net.Listener) error {
 	go func() {
 		<-ctx.Done()
 		ln.Close()
@@ -43,7 +43,10 @@ func serve(ctx context.Context, ln net.Listener) {
 		c, err := ln.Accept()
 		if err != nil {
 			log.Infow("accpet", "error", err)
-			return
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
 		}
 
 		go func(c net.Conn) {
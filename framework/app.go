@@ -0,0 +1,176 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/ironzhang/matrix/framework/pkg/model"
+)
+
+var (
+	errAppStarted    = errors.New("app started")
+	errAppNotStarted = errors.New("app not started")
+	errAppRunning    = errors.New("another app started")
+)
+
+type AppOptions struct {
+	Args     []string                              // command line arguments, without the program name
+	Getenv   func(string) (string, bool)           // looks up the env, os.LookupEnv by default
+	ReadFile func(filename string) ([]byte, error) // reads the config files, ioutil.ReadFile by default
+	Output   io.Writer                             // writes the usage of the command line, os.Stderr by default
+}
+
+// App is a framework running in-process, e.g. in integration tests. Unlike Main, it
+// does not handle signals or load the log config, and it returns errors instead of exiting.
+// While an App is started, the package level functions reading the framework, such as
+// Configs, Modules and Runners, refer to it, so the modules using them work in the App.
+// Only one App can be started at a time, Start fails while another one is not stopped.
+type App struct {
+	f       *framework
+	args    []string
+	started bool
+	stopped bool
+}
+
+func New(opts AppOptions) *App {
+	commandLine := flag.NewFlagSet("app", flag.ContinueOnError)
+	if opts.Output != nil {
+		commandLine.SetOutput(opts.Output)
+	}
+	return &App{
+		f: &framework{
+			commandLine: commandLine,
+			options:     Options{ShutdownTimeout: 10},
+			envPrefix:   "MATRIX",
+			getenv:      opts.Getenv,
+			readFile:    opts.ReadFile,
+		},
+		args: opts.Args,
+	}
+}
+
+func (a *App) Register(m Module, opts interface{}, cfg interface{}) error {
+	if a.started {
+		return errAppStarted
+	}
+	return a.f.register(m, opts, cfg)
+}
+
+func (a *App) AddCommand(name, usage string) *Command {
+	return a.f.AddCommand(name, usage)
+}
+
+func (a *App) SetRestartPolicy(module string, p RestartPolicy) {
+	a.f.SetRestartPolicy(module, p)
+}
+
+// Start parses the args, loads the configs, inits the modules and starts the runners.
+// The runners are stopped when ctx is done or Stop is called.
+func (a *App) Start(ctx context.Context) error {
+	if a.started {
+		return errAppStarted
+	}
+	if err := a.acquire(); err != nil {
+		return err
+	}
+	f := a.f
+	args, err := f.selectCommand(a.args)
+	if err != nil {
+		a.release()
+		return fmt.Errorf("select command: %v", err)
+	}
+	if err = f.parseCommandLine(args); err != nil {
+		a.release()
+		return fmt.Errorf("parse command line: %v", err)
+	}
+	if err = f.loadConfig(); err != nil {
+		a.release()
+		return err
+	}
+	if err = f.checkAppConfig(); err != nil {
+		a.release()
+		return fmt.Errorf("check app config: %v", err)
+	}
+	if err = f.start(ctx); err != nil {
+		a.release()
+		return err
+	}
+	a.started = true
+	return nil
+}
+
+func (a *App) acquire() error {
+	app.Lock()
+	defer app.Unlock()
+	if app.f != nil {
+		return errAppRunning
+	}
+	app.f = a.f
+	return nil
+}
+
+func (a *App) release() {
+	app.Lock()
+	if app.f == a.f {
+		app.f = nil
+	}
+	app.Unlock()
+}
+
+// Stop stops the runners, waits for them to return and finis the modules. It returns
+// the error which stopped the app, e.g. a failed runner.
+func (a *App) Stop() error {
+	if !a.started {
+		return errAppNotStarted
+	}
+	if a.stopped {
+		return nil
+	}
+	a.stopped = true
+	defer a.release()
+	a.f.cancel()
+	return a.f.wait()
+}
+
+// Done returns a channel which is closed when the app is stopping, e.g. a runner failed.
+func (a *App) Done() <-chan struct{} {
+	if !a.started {
+		return nil
+	}
+	return a.f.ctx.Done()
+}
+
+func (a *App) Options() Options {
+	return a.f.options
+}
+
+func (a *App) Modules() []Module {
+	return a.f.modules
+}
+
+func (a *App) Runners() []RunnerStatus {
+	return a.f.Runners()
+}
+
+func (a *App) ModuleStatuses() []ModuleStatus {
+	return a.f.ModuleStatuses()
+}
+
+func (a *App) Flags() *model.Values {
+	return &a.f.flags
+}
+
+func (a *App) Configs() *model.Values {
+	return &a.f.configs
+}
+
+func (a *App) CommandName() string {
+	return a.f.CommandName()
+}
+
+func (a *App) ReloadAppConfig() error {
+	return a.f.reloadAppConfig()
+}
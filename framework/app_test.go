@@ -0,0 +1,256 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type appModule struct {
+	testModule
+	initErr error
+	runErr  error
+	events  *[]string
+}
+
+func (m *appModule) Init() error {
+	*m.events = append(*m.events, "init "+m.name)
+	return m.initErr
+}
+
+func (m *appModule) Fini() error {
+	*m.events = append(*m.events, "fini "+m.name)
+	return nil
+}
+
+func (m *appModule) Run(ctx context.Context) error {
+	if m.runErr != nil {
+		return m.runErr
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestApp(t *testing.T) {
+	files := map[string]string{
+		"cfg.json": `{"a": {"Addr": ":6060", "Verbose": 1}}`,
+	}
+	env := map[string]string{
+		"MATRIX_B_VERBOSE": "3",
+	}
+	app := New(AppOptions{
+		Args: []string{"-config-file", "cfg.json", "-a.Verbose", "2"},
+		Getenv: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+		ReadFile: func(name string) ([]byte, error) {
+			if data, ok := files[name]; ok {
+				return []byte(data), nil
+			}
+			return nil, os.ErrNotExist
+		},
+	})
+
+	var events []string
+	opts := &testOptions{}
+	a := &testConfig{}
+	b := &testConfig{}
+	ma := &appModule{events: &events}
+	ma.name = "a"
+	mb := &appModule{events: &events}
+	mb.name, mb.depends = "b", []string{"a"}
+	if err := app.Register(mb, nil, b); err != nil {
+		t.Fatalf("register b: %v", err)
+	}
+	if err := app.Register(ma, opts, a); err != nil {
+		t.Fatalf("register a: %v", err)
+	}
+	if err := app.Register(ma, nil, nil); err == nil {
+		t.Errorf("register a again expect error but not")
+	}
+
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if got, want := app.Options().ConfigFile, "cfg.json"; got != want {
+		t.Errorf("config file: got(%q) != want(%q)", got, want)
+	}
+	if got, want := opts.Verbose, 2; got != want {
+		t.Errorf("a options verbose: got(%v) != want(%v)", got, want)
+	}
+	if got, want := *a, (testConfig{Addr: ":6060", Verbose: 1}); got != want {
+		t.Errorf("a config: got(%+v) != want(%+v)", got, want)
+	}
+	if got, want := *b, (testConfig{Verbose: 3}); got != want {
+		t.Errorf("b config: got(%+v) != want(%+v)", got, want)
+	}
+	for _, s := range app.Runners() {
+		if !s.Running {
+			t.Errorf("runner %s not running", s.Module)
+		}
+	}
+	if Configs() != app.Configs() || len(Modules()) != 2 {
+		t.Errorf("package functions not refer to the started app")
+	}
+	if err := New(AppOptions{}).Start(context.Background()); err != errAppRunning {
+		t.Errorf("start another app: got(%v) != want(%v)", err, errAppRunning)
+	}
+
+	if err := app.Stop(); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+	if Configs() != &f.configs {
+		t.Errorf("package functions refer to the stopped app")
+	}
+	if got, want := events, []string{"init a", "init b", "fini b", "fini a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events: got(%v) != want(%v)", got, want)
+	}
+}
+
+func TestAppFailure(t *testing.T) {
+	tests := []struct {
+		initErr error
+		runErr  error
+		events  []string
+		err     string
+	}{
+		{
+			initErr: errors.New("bad init"),
+			events:  []string{"init a", "init b", "fini a"},
+			err:     "init b: bad init",
+		},
+		{
+			runErr: errors.New("bad run"),
+			events: []string{"init a", "init b", "fini b", "fini a"},
+			err:    "run b: bad run",
+		},
+	}
+	for i, tt := range tests {
+		var events []string
+		app := New(AppOptions{})
+		for _, name := range []string{"a", "b"} {
+			m := &appModule{events: &events}
+			m.name = name
+			if name == "b" {
+				m.depends, m.initErr, m.runErr = []string{"a"}, tt.initErr, tt.runErr
+			}
+			if err := app.Register(m, nil, nil); err != nil {
+				t.Fatalf("tests[%d]: register %s: %v", i, name, err)
+			}
+		}
+
+		err := app.Start(context.Background())
+		if err == nil {
+			select {
+			case <-app.Done():
+			case <-time.After(time.Second):
+				t.Errorf("tests[%d]: app not done", i)
+			}
+			err = app.Stop()
+		}
+		if got, want := fmt.Sprint(err), tt.err; got != want {
+			t.Errorf("tests[%d]: error: got(%v) != want(%v)", i, got, want)
+		}
+		if got, want := events, tt.events; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: events: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestAppRestartPolicy(t *testing.T) {
+	tests := []struct {
+		args   []string
+		policy RestartPolicy
+		err    string
+	}{
+		{
+			args:   nil,
+			policy: RestartPolicy{Policy: IgnoreFailure},
+		},
+		{
+			args:   []string{"-restart-policy", "b=restart:1h:2h:3"},
+			policy: RestartPolicy{Policy: RestartOnFailure, MinBackoff: time.Hour, MaxBackoff: 2 * time.Hour, MaxRestarts: 3},
+		},
+		{
+			args: []string{"-restart-policy", "a=restart"},
+			err:  "restart policy: a is not a runner module",
+		},
+		{
+			args: []string{"-restart-policy", "b=retry"},
+			err:  "restart policy of b: unknown policy: retry",
+		},
+	}
+	for i, tt := range tests {
+		var events []string
+		app := New(AppOptions{Args: tt.args})
+		app.Register(&testModule{name: "a"}, nil, nil)
+		b := &appModule{events: &events, runErr: errors.New("bad run")}
+		b.name = "b"
+		app.Register(b, nil, nil)
+		app.SetRestartPolicy("b", RestartPolicy{Policy: IgnoreFailure})
+
+		err := app.Start(context.Background())
+		if tt.err != "" {
+			if got := fmt.Sprint(err); got != tt.err {
+				t.Errorf("tests[%d]: error: got(%v) != want(%v)", i, got, tt.err)
+			}
+			if len(events) != 0 {
+				t.Errorf("tests[%d]: events: %v", i, events)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("tests[%d]: start: %v", i, err)
+		}
+		policy := app.f.runners[0].policy
+		app.Stop()
+		if got, want := policy, tt.policy; got != want {
+			t.Errorf("tests[%d]: policy: got(%+v) != want(%+v)", i, got, want)
+		}
+		if got, want := app.f.policies["b"], (RestartPolicy{Policy: IgnoreFailure}); got != want {
+			t.Errorf("tests[%d]: policy set by code changed: %+v", i, got)
+		}
+	}
+}
+
+type stuckRunner struct {
+	appModule
+	release chan struct{}
+}
+
+func (m *stuckRunner) Run(ctx context.Context) error {
+	<-m.release
+	return nil
+}
+
+func TestAppShutdownTimeout(t *testing.T) {
+	var events []string
+	app := New(AppOptions{Args: []string{"-shutdown-timeout", "1"}})
+	a, b, c, d := &appModule{events: &events}, &stuckRunner{appModule: appModule{events: &events}, release: make(chan struct{})}, &appModule{events: &events}, &appModule{events: &events}
+	a.name = "a"
+	b.name, b.depends = "b", []string{"a"}
+	c.name, c.depends = "c", []string{"a"}
+	d.name = "d"
+	for _, m := range []Module{a, b, c, d} {
+		if err := app.Register(m, nil, nil); err != nil {
+			t.Fatalf("register %s: %v", m.Name(), err)
+		}
+	}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer close(b.release)
+
+	// the stuck runner and the module it depends on are not finied
+	if got, want := fmt.Sprint(app.Stop()), "shutdown timeout(1s): b not stop"; got != want {
+		t.Errorf("stop: got(%v) != want(%v)", got, want)
+	}
+	if got, want := events, []string{"init a", "init b", "init c", "init d", "fini d", "fini c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events: got(%v) != want(%v)", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ironzhang/matrix/framework/pkg/model"
+)
+
+// checkConfig loads the config and log config files, runs the validators and the
+// config checkers of the modules, and writes a report of every step to w.
+// It goes on after a failed step, and returns an error if any step failed.
+func (f *framework) checkConfig(w io.Writer) error {
+	var failures int
+	check := func(step string, err error) {
+		if err != nil {
+			failures++
+			fmt.Fprintf(w, "FAIL  %s: %v\n", step, err)
+			return
+		}
+		fmt.Fprintf(w, "ok    %s\n", step)
+	}
+
+	var err error
+	if f.options.ConfigFile != "" {
+		f.sections, err = loadAppConfig(&f.configs, f.readFile, f.getenv, f.options.ConfigFile)
+		check("load app config "+f.options.ConfigFile, err)
+		if err == nil {
+			check("check app config", f.checkUnknownKeys(w))
+		}
+	}
+	f.envConfigs, err = f.loadEnvConfig()
+	check("load env config", err)
+
+	configs := f.configs.Interfaces()
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c, ok := configs[name].(model.Validator); ok {
+			check("validate "+name, c.Validate())
+		}
+	}
+
+	if f.options.LogConfigFile != "" {
+		check("load log config "+f.options.LogConfigFile, checkLogConfig(f.readFile, f.options.LogConfigFile))
+	}
+
+	modules, err := sortModules(f.modules)
+	check("sort modules", err)
+	for _, m := range modules {
+		if c, ok := m.(ConfigChecker); ok {
+			check("check "+m.Name(), c.CheckConfig())
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("config check failed: %d errors", failures)
+	}
+	fmt.Fprintln(w, "config check passed")
+	return nil
+}
+
+// checkUnknownKeys reports the unknown keys in the config files to w,
+// they are errors in strict mode.
+func (f *framework) checkUnknownKeys(w io.Writer) error {
+	keys, err := unknownKeys(&f.configs, f.readFile, f.options.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if f.options.StrictConfig {
+		return f.checkAppConfig()
+	}
+	for _, k := range keys {
+		fmt.Fprintf(w, "WARN  %s:%d: %s\n", k.File, k.Line, k)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package framework
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ironzhang/matrix/tlog"
+)
+
+type testValidConfig struct {
+	Addr string
+}
+
+func (c *testValidConfig) Validate() error {
+	if c.Addr == "" {
+		return errors.New("empty addr")
+	}
+	return nil
+}
+
+type testCheckModule struct {
+	testModule
+	err error
+}
+
+func (m *testCheckModule) CheckConfig() error {
+	return m.err
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cfg.json")
+	if err = ioutil.WriteFile(file, []byte(`{"a": {"Addr": ":80"}, "b": {"Addr": ""}, "c": {}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		logConfig string
+		checkErr  error
+		report    string
+		err       bool
+	}{
+		{
+			report: `ok    load app config ` + file + `
+FAIL  check app config: unknown keys:
+	` + file + `:1: unknown module "c"
+ok    load env config
+ok    validate a
+FAIL  validate b: empty addr
+ok    sort modules
+ok    check m
+`,
+			err: true,
+		},
+		{
+			logConfig: filepath.Join(dir, "log.json"),
+			checkErr:  errors.New("bad addr"),
+			report: `ok    load app config ` + file + `
+FAIL  check app config: unknown keys:
+	` + file + `:1: unknown module "c"
+ok    load env config
+ok    validate a
+FAIL  validate b: empty addr
+FAIL  load log config ` + filepath.Join(dir, "log.json") + `: open ` + filepath.Join(dir, "log.json") + `: no such file or directory
+ok    sort modules
+FAIL  check m: bad addr
+`,
+			err: true,
+		},
+	}
+	for i, tt := range tests {
+		var f framework
+		f.options.ConfigFile = file
+		f.options.LogConfigFile = tt.logConfig
+		f.options.StrictConfig = true
+		f.configs.Register("a", &testValidConfig{})
+		f.configs.Register("b", &testValidConfig{})
+		m := &testCheckModule{err: tt.checkErr}
+		m.name = "m"
+		f.Register(m, nil, nil)
+
+		var buf bytes.Buffer
+		err := f.checkConfig(&buf)
+		if got, want := err != nil, tt.err; got != want {
+			t.Errorf("tests[%d]: error: got(%v) != want(%v)", i, err, want)
+		}
+		if got, want := buf.String(), tt.report; got != want {
+			t.Errorf("tests[%d]: report: got:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+func TestCheckConfigPassed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logConfig := filepath.Join(dir, "log.json")
+	if err = ioutil.WriteFile(logConfig, []byte(`{"Level": "warn", "DisableStderr": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var f framework
+	f.options.LogConfigFile = logConfig
+	f.configs.Register("a", &testValidConfig{Addr: ":80"})
+	m := &testCheckModule{}
+	m.name = "m"
+	f.Register(m, nil, nil)
+
+	std := tlog.Std()
+	var buf bytes.Buffer
+	if err = f.checkConfig(&buf); err != nil {
+		t.Fatalf("check config: %v", err)
+	}
+	if tlog.Std() != std {
+		t.Errorf("std logger replaced by the checked log config")
+	}
+	want := `ok    load env config
+ok    validate a
+ok    load log config ` + logConfig + `
+ok    sort modules
+ok    check m
+config check passed
+`
+	if got := buf.String(); got != want {
+		t.Errorf("report: got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package framework
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var errNoCommand = errors.New("no command")
+
+type registration struct {
+	module Module
+	opts   interface{}
+	cfg    interface{}
+}
+
+// Command is a subcommand of the binary. The modules registered to a command
+// only run with it, the modules registered by Register run with every command.
+type Command struct {
+	name  string
+	usage string
+	regs  []registration
+}
+
+func (c *Command) Name() string {
+	return c.name
+}
+
+func (c *Command) Usage() string {
+	return c.usage
+}
+
+func (c *Command) Register(m Module, opts interface{}, cfg interface{}) {
+	for _, r := range c.regs {
+		if r.module.Name() == m.Name() {
+			panic(fmt.Sprintf("command(%s) module(%s) duplicate", c.name, m.Name()))
+		}
+	}
+	c.regs = append(c.regs, registration{module: m, opts: opts, cfg: cfg})
+}
+
+func (f *framework) flagSet() *flag.FlagSet {
+	if f.commandLine == nil {
+		f.commandLine = flag.CommandLine
+	}
+	return f.commandLine
+}
+
+func (f *framework) AddCommand(name, usage string) *Command {
+	if name == "" || strings.HasPrefix(name, "-") || name == "help" {
+		panic(fmt.Sprintf("command(%s) invalid", name))
+	}
+	if f.lookupCommand(name) != nil {
+		panic(fmt.Sprintf("command(%s) duplicate", name))
+	}
+	c := &Command{name: name, usage: usage}
+	f.commands = append(f.commands, c)
+	return c
+}
+
+func (f *framework) lookupCommand(name string) *Command {
+	for _, c := range f.commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// selectCommand selects the command by the first argument, registers its modules,
+// and returns the arguments of the command. "help [command]" and -h print the help
+// and return flag.ErrHelp, on which the binary exits with 0.
+func (f *framework) selectCommand(args []string) ([]string, error) {
+	if len(f.commands) == 0 {
+		return args, nil
+	}
+	if len(args) == 0 {
+		f.printCommands(f.flagSet().Output())
+		return nil, errNoCommand
+	}
+
+	name, help := args[0], false
+	switch name {
+	case "-h", "-help", "--help":
+		f.printCommands(f.flagSet().Output())
+		return nil, flag.ErrHelp
+	case "help":
+		if len(args) == 1 {
+			f.printCommands(f.flagSet().Output())
+			return nil, flag.ErrHelp
+		}
+		name, args, help = args[1], args[1:], true
+	}
+	c := f.lookupCommand(name)
+	if c == nil {
+		f.printCommands(f.flagSet().Output())
+		return nil, fmt.Errorf("unknown command %q", name)
+	}
+
+	for _, r := range c.regs {
+		f.Register(r.module, r.opts, r.cfg)
+	}
+	f.command = c
+	fs := f.flagSet()
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [options]\n\n", fs.Name(), c.name)
+		if c.usage != "" {
+			fmt.Fprintf(fs.Output(), "%s\n\n", c.usage)
+		}
+		fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+	if help {
+		if err := f.setupFlags(); err != nil {
+			return nil, err
+		}
+		fs.Usage()
+		return nil, flag.ErrHelp
+	}
+	return args[1:], nil
+}
+
+func (f *framework) printCommands(w io.Writer) {
+	name := f.flagSet().Name()
+	fmt.Fprintf(w, "Usage: %s <command> [options]\n\nCommands:\n", name)
+	width := len("help")
+	for _, c := range f.commands {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	for _, c := range f.commands {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, c.name, c.usage)
+	}
+	fmt.Fprintf(w, "  %-*s  %s\n", width, "help", "print the help of a command")
+	fmt.Fprintf(w, "\nUse \"%s help <command>\" for more information about a command.\n", name)
+}
+
+// CommandName returns the name of the running command, or empty if no command is registered.
+func (f *framework) CommandName() string {
+	if f.command == nil {
+		return ""
+	}
+	return f.command.name
+}
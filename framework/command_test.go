@@ -0,0 +1,106 @@
+package framework
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestSelectCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		rest    []string
+		modules []string
+		err     string
+	}{
+		{args: []string{"serve", "-a.Addr", ":80"}, command: "serve", rest: []string{"-a.Addr", ":80"}, modules: []string{"shared", "a", "b"}},
+		{args: []string{"migrate"}, command: "migrate", rest: []string{}, modules: []string{"shared", "c"}},
+		{args: []string{"help", "migrate"}, command: "migrate", modules: []string{"shared", "c"}, err: flag.ErrHelp.Error()},
+		{args: []string{}, modules: []string{"shared"}, err: errNoCommand.Error()},
+		{args: []string{"-h"}, modules: []string{"shared"}, err: flag.ErrHelp.Error()},
+		{args: []string{"help"}, modules: []string{"shared"}, err: flag.ErrHelp.Error()},
+		{args: []string{"unknown"}, modules: []string{"shared"}, err: `unknown command "unknown"`},
+	}
+	for i, tt := range tests {
+		var f framework
+		var buf bytes.Buffer
+		f.commandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+		f.commandLine.SetOutput(&buf)
+		f.Register(&testModule{name: "shared"}, nil, nil)
+		serve := f.AddCommand("serve", "run the server")
+		serve.Register(&testModule{name: "a"}, &testOptions{}, nil)
+		serve.Register(&testModule{name: "b"}, nil, &testConfig{})
+		f.AddCommand("migrate", "migrate the database").Register(&testModule{name: "c"}, nil, nil)
+
+		rest, err := f.selectCommand(tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("tests[%d]: error: got(%v) != want(%v)", i, err, tt.err)
+			}
+			if buf.Len() == 0 {
+				t.Errorf("tests[%d]: no help printed", i)
+			}
+		} else if err != nil {
+			t.Errorf("tests[%d]: select command: %v", i, err)
+			continue
+		}
+		if got, want := f.CommandName(), tt.command; got != want {
+			t.Errorf("tests[%d]: command: got(%q) != want(%q)", i, got, want)
+		}
+		if tt.err == "" {
+			if got, want := rest, tt.rest; !reflect.DeepEqual(got, want) {
+				t.Errorf("tests[%d]: args: got(%v) != want(%v)", i, got, want)
+			}
+		}
+		var modules []string
+		for _, m := range f.modules {
+			modules = append(modules, m.Name())
+		}
+		if got, want := modules, tt.modules; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: modules: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	var f framework
+	var buf bytes.Buffer
+	f.commandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	f.commandLine.SetOutput(&buf)
+	f.AddCommand("serve", "run the server").Register(&testModule{name: "a"}, &testOptions{}, nil)
+	f.AddCommand("migrate", "migrate the database")
+
+	f.printCommands(&buf)
+	want := `Usage: test <command> [options]
+
+Commands:
+  serve    run the server
+  migrate  migrate the database
+  help     print the help of a command
+
+Use "test help <command>" for more information about a command.
+`
+	if got := buf.String(); got != want {
+		t.Errorf("commands: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if _, err := f.selectCommand([]string{"help", "serve"}); err != flag.ErrHelp {
+		t.Fatalf("select command: got(%v) != want(%v)", err, flag.ErrHelp)
+	}
+	want = `Usage: test serve [options]
+
+run the server
+
+Options:
+  -a.Addr value
+    	listen address
+  -a.Verbose value
+    	verbose level
+`
+	if got := buf.String(); !bytes.HasPrefix([]byte(got), []byte(want)) {
+		t.Errorf("usage: got:\n%s\nwant prefix:\n%s", got, want)
+	}
+}
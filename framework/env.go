@@ -0,0 +1,120 @@
+package framework
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ironzhang/matrix/framework/pkg/flags"
+)
+
+// EnvNaming returns the env name of the option or config field, an empty name disables it.
+type EnvNaming func(prefix, name string) string
+
+// DefaultEnvNaming converts backend-module.Addr to MATRIX_BACKEND_MODULE_ADDR with prefix MATRIX.
+func DefaultEnvNaming(prefix, name string) string {
+	s := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if prefix == "" {
+		return s
+	}
+	return prefix + "_" + s
+}
+
+func (f *framework) lookupEnv(name string) (string, string, bool) {
+	naming := f.envNaming
+	if naming == nil {
+		naming = DefaultEnvNaming
+	}
+	env := naming(f.envPrefix, name)
+	if env == "" {
+		return "", "", false
+	}
+	lookup := f.getenv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	value, ok := lookup(env)
+	return env, value, ok
+}
+
+// loadEnvOptions sets the options which are not set by command line from env.
+func (f *framework) loadEnvOptions() error {
+	for _, fls := range f.optionFlags {
+		for _, fl := range fls {
+			if _, ok := f.optionSources[fl.Name]; ok {
+				continue
+			}
+			env, value, ok := f.lookupEnv(fl.Name)
+			if !ok {
+				continue
+			}
+			if err := fl.Value.Set(value); err != nil {
+				return fmt.Errorf("env %s: %v", env, err)
+			}
+			f.optionSources[fl.Name] = SourceEnv
+		}
+	}
+	return nil
+}
+
+// loadEnvConfig sets the config fields from env, and returns the values set,
+// keyed by module and field path, which are kept on config reload.
+func (f *framework) loadEnvConfig() (map[string]map[string]interface{}, error) {
+	envs := make(map[string]map[string]interface{})
+	for module, cfg := range f.configs.Interfaces() {
+		fs := flag.NewFlagSet(module, flag.ContinueOnError)
+		if err := flags.SetupLenient(fs, cfg, "", ""); err != nil {
+			return nil, fmt.Errorf("setup %s module config: %v", module, err)
+		}
+
+		var err error
+		var paths []string
+		fs.VisitAll(func(fl *flag.Flag) {
+			if err != nil {
+				return
+			}
+			env, value, ok := f.lookupEnv(module + "." + fl.Name)
+			if !ok {
+				return
+			}
+			if e := fl.Value.Set(value); e != nil {
+				err = fmt.Errorf("env %s: %v", env, e)
+				return
+			}
+			paths = append(paths, fl.Name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			continue
+		}
+
+		leaves, err := flatten(cfg)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{})
+		for _, p := range paths {
+			if v, ok := leaves[p]; ok {
+				m[p] = v
+			}
+		}
+		envs[module] = m
+	}
+	return envs, nil
+}
+
+func setPath(m map[string]interface{}, path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := m[k].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[k] = sub
+		}
+		m = sub
+	}
+	m[keys[len(keys)-1]] = v
+}
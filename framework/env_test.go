@@ -0,0 +1,110 @@
+package framework
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDefaultEnvNaming(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		env    string
+	}{
+		{prefix: "MATRIX", name: "backend-module.Addr", env: "MATRIX_BACKEND_MODULE_ADDR"},
+		{prefix: "MATRIX", name: "config-file", env: "MATRIX_CONFIG_FILE"},
+		{prefix: "", name: "a.Sub.Verbose", env: "A_SUB_VERBOSE"},
+	}
+	for i, tt := range tests {
+		if got, want := DefaultEnvNaming(tt.prefix, tt.name), tt.env; got != want {
+			t.Errorf("tests[%d]: got(%s) != want(%s)", i, got, want)
+		}
+	}
+}
+
+func testEnv(m map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+func TestEnvOptions(t *testing.T) {
+	var f framework
+	f.envPrefix = "T"
+	f.getenv = testEnv(map[string]string{
+		"T_A_ADDR":    ":7070",
+		"T_A_VERBOSE": "3",
+	})
+	f.commandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := &testOptions{Addr: ":6060"}
+	f.flags.Register("a", opts)
+	if err := f.parseCommandLine([]string{"-a.Verbose", "2"}); err != nil {
+		t.Fatalf("parse command line: %v", err)
+	}
+
+	if got, want := *opts, (testOptions{Addr: ":7070", Verbose: 2}); got != want {
+		t.Errorf("options: got(%+v) != want(%+v)", got, want)
+	}
+	if got, want := f.optionSources["a.Addr"], SourceEnv; got != want {
+		t.Errorf("a.Addr source: got(%s) != want(%s)", got, want)
+	}
+	if got, want := f.optionSources["a.Verbose"], SourceFlag; got != want {
+		t.Errorf("a.Verbose source: got(%s) != want(%s)", got, want)
+	}
+}
+
+func TestEnvConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	write := func(s string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var f framework
+	f.envPrefix = "T"
+	f.getenv = testEnv(map[string]string{
+		"T_A_ADDR":    ":7070",
+		"T_B_VERBOSE": "3",
+	})
+	a := &testConfig{Addr: ":6060"}
+	b := &testConfig{Addr: ":6060"}
+	f.configs.Register("a", a)
+	f.configs.Register("b", b)
+	f.options.ConfigFile = file.Name()
+
+	write(`{"a": {"Addr": ":6061", "Verbose": 1}, "b": {"Verbose": 1}}`)
+	if f.sections, err = loadAppConfig(&f.configs, nil, nil, file.Name()); err != nil {
+		t.Fatalf("load app config: %v", err)
+	}
+	if f.envConfigs, err = f.loadEnvConfig(); err != nil {
+		t.Fatalf("load env config: %v", err)
+	}
+	if got, want := *a, (testConfig{Addr: ":7070", Verbose: 1}); got != want {
+		t.Errorf("a: got(%+v) != want(%+v)", got, want)
+	}
+	if got, want := *b, (testConfig{Addr: ":6060", Verbose: 3}); got != want {
+		t.Errorf("b: got(%+v) != want(%+v)", got, want)
+	}
+
+	f.configs.Seal()
+
+	write(`{"a": {"Addr": ":6061", "Verbose": 2}, "b": {"Verbose": 2}}`)
+	if err = f.reloadAppConfig(); err != nil {
+		t.Fatalf("reload app config: %v", err)
+	}
+	if got, want := snapshotOf(&f, "a"), (testConfig{Addr: ":7070", Verbose: 2, reloads: 1}); got != want {
+		t.Errorf("a: got(%+v) != want(%+v)", got, want)
+	}
+	if got, want := snapshotOf(&f, "b"), (testConfig{Addr: ":6060", Verbose: 3, reloads: 1}); got != want {
+		t.Errorf("b: got(%+v) != want(%+v)", got, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ironzhang/matrix/framework/pkg/flags"
@@ -16,10 +17,17 @@ import (
 )
 
 type Options struct {
-	ConfigFile       string `json:"config-file" usage:"指定配置文件选项"`
-	ConfigExample    string `json:"config-example" usage:"生成配置示例选项"`
+	ConfigFile       string `json:"config-file" usage:"指定配置文件选项, 多个文件以逗号分隔, 后面的文件按模块深度合并覆盖前面的, 格式由扩展名决定(.json, .jsonc, .yaml, .yml, .toml)"`
+	ConfigDump       string `json:"config-dump" usage:"输出合并后的配置及每个值的来源到指定文件, -表示标准输出"`
+	ConfigExample    string `json:"config-example" usage:"生成配置示例选项, 格式由扩展名决定(.json, .jsonc, .yaml, .yml, .toml), jsonc和yaml格式带有注释"`
+	CheckConfig      bool   `json:"check-config" usage:"检查配置文件和日志配置文件, 执行各模块的配置校验后退出, 不启动模块"`
 	LogConfigFile    string `json:"log-config-file" usage:"指定日志配置文件选项"`
 	LogConfigExample string `json:"log-config-example" usage:"生成日志配置示例选项"`
+	ShutdownTimeout  int    `json:"shutdown-timeout" usage:"指定关闭超时时间(秒)"`
+	ConfigWatch      int    `json:"config-watch" usage:"指定配置文件检查间隔(秒), 0表示不检查"`
+	StrictConfig     bool   `json:"strict-config" usage:"严格检查配置文件, 存在未注册的模块或未知的字段时启动失败, 否则仅告警"`
+
+	RestartPolicy map[string]string `json:"restart-policy" usage:"指定运行模块的失败策略, 覆盖模块的默认策略, 格式为模块=策略[:最小退避[:最大退避[:最大重启次数]]], 多个以逗号分隔, 策略为stop, restart或ignore, 如a=restart:1s:1m:10"`
 }
 
 type Module interface {
@@ -29,7 +37,20 @@ type Module interface {
 }
 
 type Runner interface {
-	Run(ctx context.Context)
+	Run(ctx context.Context) error
+}
+
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+type ReadyChecker interface {
+	CheckReady(ctx context.Context) error
+}
+
+// ConfigChecker checks the config of a module for -check-config, without accessing the network.
+type ConfigChecker interface {
+	CheckConfig() error
 }
 
 type framework struct {
@@ -38,28 +59,69 @@ type framework struct {
 	flags       model.Values
 	configs     model.Values
 	modules     []Module
+	policies    map[string]RestartPolicy
+	commands    []*Command
+	command     *Command
+
+	optionFlags   map[string][]*flag.Flag
+	optionSources map[string]string
+
+	envPrefix  string
+	envNaming  EnvNaming
+	envConfigs map[string]map[string]interface{}
+	getenv     func(string) (string, bool)
+	readFile   readFunc
+
+	reloadMu sync.Mutex
+	sections map[string]byteSlice
+
+	mu       sync.Mutex
+	runners  []*runner
+	statuses map[string]*moduleStatus
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started []Module
+	once    sync.Once
+	failure error
 }
 
-func (f *framework) parseCommandLine() (err error) {
+// setupFlags defines the flags of the framework options and the module options.
+func (f *framework) setupFlags() (err error) {
 	if f.commandLine == nil {
 		f.commandLine = flag.CommandLine
 	}
 	if err = f.flags.Register("", &f.options); err != nil {
 		return err
 	}
+	seen := make(map[string]bool)
+	f.commandLine.VisitAll(func(fl *flag.Flag) { seen[fl.Name] = true })
 	for module, opts := range f.flags.Interfaces() {
 		if err = flags.Setup(f.commandLine, opts, module, ""); err != nil {
 			return err
 		}
+		f.recordFlags(module, seen)
 	}
-	return f.commandLine.Parse(os.Args[1:])
+	return nil
+}
+
+func (f *framework) parseCommandLine(args []string) (err error) {
+	if err = f.setupFlags(); err != nil {
+		return err
+	}
+	if err = f.commandLine.Parse(args); err != nil {
+		return err
+	}
+	f.optionSources = make(map[string]string)
+	f.commandLine.Visit(func(fl *flag.Flag) { f.optionSources[fl.Name] = SourceFlag })
+	return f.loadEnvOptions()
 }
 
 func (f *framework) doCommandLine() (err error) {
 	var quit bool
 
 	if f.options.ConfigExample != "" {
-		if jsoncfg.WriteToFile(f.options.ConfigExample, f.configs.Interfaces()); err != nil {
+		if err = jsoncfg.WriteAnnotatedToFile(f.options.ConfigExample, f.configs.Interfaces()); err != nil {
 			return fmt.Errorf("generate config example: %v", err)
 		}
 		quit = true
@@ -78,61 +140,176 @@ func (f *framework) doCommandLine() (err error) {
 	return nil
 }
 
-func (f *framework) main() (err error) {
+// start inits the modules and starts the runners, the inited modules are finied if an init fails.
+func (f *framework) start(parent context.Context) (err error) {
 	log := tlog.Std().Sugar()
 
+	// module sort
+	modules, err := sortModules(f.modules)
+	if err != nil {
+		log.Errorw("sort modules", "error", err)
+		return fmt.Errorf("sort modules: %v", err)
+	}
+
+	// restart policy
+	policies, err := f.restartPolicies(modules)
+	if err != nil {
+		log.Errorw("restart policy", "error", err)
+		return err
+	}
+
 	// module init
-	for _, m := range f.modules {
-		if err = m.Init(); err != nil {
+	for i, m := range modules {
+		start := time.Now()
+		err = m.Init()
+		f.moduleStatus(m.Name()).inited(time.Since(start), err)
+		if err != nil {
 			log.Errorw("init", "module", m.Name(), "error", err)
+			f.fini(modules[:i])
 			return fmt.Errorf("init %s: %v", m.Name(), err)
 		}
 		log.Debugw("init", "module", m.Name())
 	}
 
-	// quit signal
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, os.Interrupt, os.Kill)
-		<-ch
-		cancel()
-		time.Sleep(10 * time.Second)
-		fmt.Fprintf(os.Stderr, "wait 10s, force exit")
-		log.Sync()
-		os.Exit(-3)
-	}()
-
 	// module run
-	var wg sync.WaitGroup
-	for _, m := range f.modules {
-		if r, ok := m.(Runner); ok {
-			wg.Add(1)
-			go func(r Runner) {
-				defer wg.Done()
-				r.Run(ctx)
-			}(r)
+	f.ctx, f.cancel = context.WithCancel(parent)
+	f.started = modules
+	stop := func(err error) {
+		f.once.Do(func() { f.failure = err })
+		f.cancel()
+	}
+	for _, m := range modules {
+		if _, ok := m.(Runner); !ok {
+			f.moduleStatus(m.Name()).running()
 		}
 	}
-	wg.Wait()
+	runners := startRunners(f.ctx, modules, policies, f.moduleStatus, stop)
+	f.mu.Lock()
+	f.runners = runners
+	f.mu.Unlock()
+
+	// config watch
+	if f.options.ConfigFile != "" && f.options.ConfigWatch > 0 {
+		go f.watchConfigFile(f.ctx, time.Duration(f.options.ConfigWatch)*time.Second)
+	}
+	return nil
+}
+
+// wait waits for the runners to stop, and then finis the modules.
+func (f *framework) wait() error {
+	defer f.cancel()
+
+	// wait runners
+	f.mu.Lock()
+	runners := f.runners
+	f.mu.Unlock()
+	if err := waitRunners(f.ctx, runners, f.shutdownTimeout()); err != nil {
+		f.fini(stoppedModules(f.started, runners))
+		return err
+	}
 
 	// module fini
-	for i := len(f.modules) - 1; i >= 0; i-- {
-		m := f.modules[i]
-		if err = m.Fini(); err != nil {
+	f.fini(f.started)
+	return f.failure
+}
+
+func (f *framework) fini(modules []Module) {
+	log := tlog.Std().Sugar()
+	for i := len(modules) - 1; i >= 0; i-- {
+		m := modules[i]
+		start := time.Now()
+		err := m.Fini()
+		f.moduleStatus(m.Name()).finied(time.Since(start), err)
+		if err != nil {
 			log.Errorw("fini", "module", m.Name(), "error", err)
 			continue
 		}
 		log.Debugw("fini", "module", m.Name())
 	}
+}
+
+func (f *framework) main() (err error) {
+	if err = f.start(context.Background()); err != nil {
+		return err
+	}
+
+	// quit signal
+	go f.waitSignal(f.cancel)
+
+	return f.wait()
+}
+
+// loadConfig loads the app config from the config files and env, validates and seals it.
+func (f *framework) loadConfig() (err error) {
+	if f.sections, err = loadAppConfig(&f.configs, f.readFile, f.getenv, f.options.ConfigFile); err != nil {
+		return fmt.Errorf("load app config: %v", err)
+	}
+	if f.envConfigs, err = f.loadEnvConfig(); err != nil {
+		return fmt.Errorf("load env config: %v", err)
+	}
+	if err = f.configs.Validate(); err != nil {
+		return fmt.Errorf("validate app config: %v", err)
+	}
+	f.configs.Seal()
 	return nil
 }
 
+func (f *framework) waitSignal(cancel context.CancelFunc) {
+	log := tlog.Std().Sugar()
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	var stopping bool
+	for sig := range ch {
+		if sig == syscall.SIGHUP {
+			f.reload("signal")
+			continue
+		}
+		if !stopping {
+			stopping = true
+			log.Infow("shutdown", "signal", sig, "timeout", f.shutdownTimeout())
+			cancel()
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "receive %s signal again, force exit\n", sig)
+		log.Sync()
+		os.Exit(-3)
+	}
+}
+
+func (f *framework) shutdownTimeout() time.Duration {
+	if f.options.ShutdownTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(f.options.ShutdownTimeout) * time.Second
+}
+
+func (f *framework) Runners() []RunnerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := make([]RunnerStatus, 0, len(f.runners))
+	for _, r := range f.runners {
+		status = append(status, r.Status())
+	}
+	return status
+}
+
 func (f *framework) Main() {
 	var err error
 
+	// select command
+	args, err := f.selectCommand(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "select command: %v\n", err)
+		os.Exit(2)
+	}
+
 	// parse command line
-	if err = f.parseCommandLine(); err != nil {
+	if err = f.parseCommandLine(args); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "parse command line: %v\n", err)
 		os.Exit(3)
 	}
@@ -143,20 +320,44 @@ func (f *framework) Main() {
 		os.Exit(3)
 	}
 
+	// check config
+	if f.options.CheckConfig {
+		if err = f.checkConfig(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(3)
+		}
+		os.Exit(0)
+	}
+
 	// load app config
-	if err = loadAppConfig(&f.configs, f.options.ConfigFile); err != nil {
-		fmt.Fprintf(os.Stderr, "load app config: %v\n", err)
+	if err = f.loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(3)
 	}
 
+	// dump app config
+	if f.options.ConfigDump != "" {
+		if err = f.dumpAppConfigToFile(f.options.ConfigDump); err != nil {
+			fmt.Fprintf(os.Stderr, "dump app config: %v\n", err)
+			os.Exit(3)
+		}
+		os.Exit(0)
+	}
+
 	// load log config
-	log, err := loadLogConfig(f.options.LogConfigFile)
+	log, err := loadLogConfig(f.readFile, f.options.LogConfigFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "load log config: %v\n", err)
 		os.Exit(3)
 	}
 	defer log.Sync()
 
+	// check app config
+	if err = f.checkAppConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "check app config: %v\n", err)
+		os.Exit(3)
+	}
+
 	// main
 	if err = f.main(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -164,28 +365,86 @@ func (f *framework) Main() {
 	}
 }
 
+// restartPolicies returns the policies set by SetRestartPolicy, overridden by the
+// restart-policy option.
+func (f *framework) restartPolicies(modules []Module) (map[string]RestartPolicy, error) {
+	policies := make(map[string]RestartPolicy, len(f.policies)+len(f.options.RestartPolicy))
+	for module, p := range f.policies {
+		policies[module] = p
+	}
+	runners := make(map[string]bool)
+	for _, m := range modules {
+		if _, ok := m.(Runner); ok {
+			runners[m.Name()] = true
+		}
+	}
+	for module, s := range f.options.RestartPolicy {
+		if !runners[module] {
+			return nil, fmt.Errorf("restart policy: %s is not a runner module", module)
+		}
+		p, err := parseRestartPolicy(s)
+		if err != nil {
+			return nil, fmt.Errorf("restart policy of %s: %v", module, err)
+		}
+		policies[module] = p
+	}
+	return policies, nil
+}
+
+func (f *framework) SetRestartPolicy(module string, p RestartPolicy) {
+	if f.policies == nil {
+		f.policies = make(map[string]RestartPolicy)
+	}
+	f.policies[module] = p
+}
+
 func (f *framework) Register(m Module, opts interface{}, cfg interface{}) {
+	if err := f.register(m, opts, cfg); err != nil {
+		panic(err)
+	}
+}
+
+func (f *framework) register(m Module, opts interface{}, cfg interface{}) error {
 	for _, v := range f.modules {
 		if v.Name() == m.Name() {
-			panic(fmt.Sprintf("module(%s) duplicate", m.Name()))
+			return fmt.Errorf("module(%s) duplicate", m.Name())
 		}
 	}
-	f.modules = append(f.modules, m)
 
 	if opts != nil {
 		if err := f.flags.Register(m.Name(), opts); err != nil {
-			panic(err)
+			return err
 		}
 	}
 
 	if cfg != nil {
 		if err := f.configs.Register(m.Name(), cfg); err != nil {
-			panic(err)
+			return err
 		}
 	}
+
+	f.modules = append(f.modules, m)
+	f.moduleStatus(m.Name())
+	return nil
 }
 
-var f = &framework{}
+var f = &framework{options: Options{ShutdownTimeout: 10}, envPrefix: "MATRIX"}
+
+// app is the framework of the started App, which the package level functions
+// reading the state of the framework refer to instead of f.
+var app struct {
+	sync.Mutex
+	f *framework
+}
+
+func current() *framework {
+	app.Lock()
+	defer app.Unlock()
+	if app.f != nil {
+		return app.f
+	}
+	return f
+}
 
 func Main() {
 	f.Main()
@@ -195,6 +454,14 @@ func Register(m Module, opts interface{}, cfg interface{}) {
 	f.Register(m, opts, cfg)
 }
 
+func AddCommand(name, usage string) *Command {
+	return f.AddCommand(name, usage)
+}
+
+func CommandName() string {
+	return current().CommandName()
+}
+
 func SetCommandLine(commandLine *flag.FlagSet) {
 	f.commandLine = commandLine
 }
@@ -203,10 +470,56 @@ func SetOptions(opts Options) {
 	f.options = opts
 }
 
+func Modules() []Module {
+	return current().modules
+}
+
+func ModuleStatuses() []ModuleStatus {
+	return current().ModuleStatuses()
+}
+
+func SetEnvPrefix(prefix string) {
+	f.envPrefix = prefix
+}
+
+func SetEnvNaming(naming EnvNaming) {
+	f.envNaming = naming
+}
+
+func SetRestartPolicy(module string, p RestartPolicy) {
+	f.SetRestartPolicy(module, p)
+}
+
+func Runners() []RunnerStatus {
+	return current().Runners()
+}
+
+func ConfigFile() string {
+	return current().options.ConfigFile
+}
+
+// SaveAppConfig writes the fields of the module config changed since prev, the
+// snapshot before the change, to the last config file.
+func SaveAppConfig(module string, prev interface{}) error {
+	return current().saveAppConfig(module, prev)
+}
+
+func DiffAppConfig() ([]ConfigDiff, error) {
+	return current().diffAppConfig()
+}
+
+func ListOptions() map[string][]Option {
+	return current().listOptions()
+}
+
+func ModuleOptions(module string) ([]Option, bool) {
+	return current().moduleOptions(module)
+}
+
 func Flags() *model.Values {
-	return &f.flags
+	return &current().flags
 }
 
 func Configs() *model.Values {
-	return &f.configs
+	return &current().configs
 }
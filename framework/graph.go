@@ -0,0 +1,92 @@
+package framework
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Depender interface {
+	Depends() []string
+}
+
+func dependsOf(m Module) []string {
+	if d, ok := m.(Depender); ok {
+		return d.Depends()
+	}
+	return nil
+}
+
+func checkDepends(modules []Module) error {
+	names := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		names[m.Name()] = true
+	}
+	var missing []string
+	for _, m := range modules {
+		for _, d := range dependsOf(m) {
+			if !names[d] {
+				missing = append(missing, fmt.Sprintf("%s -> %s", m.Name(), d))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing dependencies: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// sortModules returns the modules in dependency order, every module comes
+// after the modules it depends on. Independent modules keep the register order.
+func sortModules(modules []Module) ([]Module, error) {
+	if err := checkDepends(modules); err != nil {
+		return nil, err
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	index := make(map[string]int, len(modules))
+	for i, m := range modules {
+		index[m.Name()] = i
+	}
+	states := make([]int, len(modules))
+	sorted := make([]Module, 0, len(modules))
+
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		name := modules[i].Name()
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			for j, s := range path {
+				if s == name {
+					return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[j:], name), " -> "))
+				}
+			}
+		}
+
+		states[i] = visiting
+		path = append(path, name)
+		for _, d := range dependsOf(modules[i]) {
+			if err := visit(index[d]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+		sorted = append(sorted, modules[i])
+		return nil
+	}
+
+	for i := range modules {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
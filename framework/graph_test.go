@@ -0,0 +1,110 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testModule struct {
+	name    string
+	depends []string
+}
+
+func (m *testModule) Name() string {
+	return m.name
+}
+
+func (m *testModule) Depends() []string {
+	return m.depends
+}
+
+func (m *testModule) Init() error {
+	return nil
+}
+
+func (m *testModule) Fini() error {
+	return nil
+}
+
+func newTestModules(deps map[string][]string, names ...string) []Module {
+	modules := make([]Module, 0, len(names))
+	for _, name := range names {
+		modules = append(modules, &testModule{name: name, depends: deps[name]})
+	}
+	return modules
+}
+
+func moduleNames(modules []Module) []string {
+	names := make([]string, 0, len(modules))
+	for _, m := range modules {
+		names = append(names, m.Name())
+	}
+	return names
+}
+
+func TestSortModules(t *testing.T) {
+	tests := []struct {
+		names []string
+		deps  map[string][]string
+		want  []string
+	}{
+		{
+			names: []string{"a", "b", "c"},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			names: []string{"micro", "etcd", "backend"},
+			deps:  map[string][]string{"micro": {"etcd"}},
+			want:  []string{"etcd", "micro", "backend"},
+		},
+		{
+			names: []string{"a", "b", "c", "d"},
+			deps:  map[string][]string{"a": {"d", "b"}, "b": {"c"}, "d": {"c"}},
+			want:  []string{"c", "d", "b", "a"},
+		},
+	}
+	for i, tt := range tests {
+		modules, err := sortModules(newTestModules(tt.deps, tt.names...))
+		if err != nil {
+			t.Errorf("tests[%d]: sort modules: %v", i, err)
+			continue
+		}
+		if got, want := moduleNames(modules), tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestSortModulesError(t *testing.T) {
+	tests := []struct {
+		names []string
+		deps  map[string][]string
+		err   string
+	}{
+		{
+			names: []string{"a", "b"},
+			deps:  map[string][]string{"a": {"x"}, "b": {"y"}},
+			err:   "missing dependencies: a -> x, b -> y",
+		},
+		{
+			names: []string{"a", "b", "c"},
+			deps:  map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			err:   "dependency cycle: b -> c -> b",
+		},
+		{
+			names: []string{"a"},
+			deps:  map[string][]string{"a": {"a"}},
+			err:   "dependency cycle: a -> a",
+		},
+	}
+	for i, tt := range tests {
+		_, err := sortModules(newTestModules(tt.deps, tt.names...))
+		if err == nil {
+			t.Errorf("tests[%d]: sort modules expect error but not", i)
+			continue
+		}
+		if got, want := err.Error(), tt.err; got != want {
+			t.Errorf("tests[%d]: error: %q != %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ironzhang/matrix/jsoncfg"
+)
+
+// includeKey is the top-level key of the config files to include other files,
+// which are layered before the including file. The paths are relative to the including file.
+const includeKey = "include"
+
+// readFunc reads a config file, nil reads it from the file system.
+type readFunc func(filename string) ([]byte, error)
+
+func (r readFunc) ReadFile(filename string) ([]byte, error) {
+	if r == nil {
+		return ioutil.ReadFile(filename)
+	}
+	return r(filename)
+}
+
+type layer struct {
+	file   string
+	format string
+	data   []byte
+	tree   map[string]interface{}
+}
+
+// configFiles splits the comma separated config files.
+func configFiles(s string) []string {
+	var files []string
+	for _, file := range strings.Split(s, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// readLayers reads the config files and the files they include, the later layers override the earlier ones.
+func readLayers(read readFunc, files []string) ([]layer, error) {
+	var layers []layer
+	visiting := make(map[string]bool)
+	for _, file := range files {
+		if err := readLayer(read, &layers, visiting, file); err != nil {
+			return nil, err
+		}
+	}
+	return layers, nil
+}
+
+func readLayer(read readFunc, layers *[]layer, visiting map[string]bool, file string) error {
+	if visiting[file] {
+		return fmt.Errorf("include cycle: %s", file)
+	}
+	visiting[file] = true
+	defer delete(visiting, file)
+
+	data, err := read.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	l := layer{file: file, format: jsoncfg.FormatOf(file), data: data}
+	js, err := jsoncfg.ToJSON(l.format, data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if err = json.Unmarshal(js, &l.tree); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	includes, err := parseIncludes(l.tree[includeKey])
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	delete(l.tree, includeKey)
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(file), inc)
+		}
+		if err = readLayer(read, layers, visiting, inc); err != nil {
+			return err
+		}
+	}
+	*layers = append(*layers, l)
+	return nil
+}
+
+func parseIncludes(v interface{}) ([]string, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{x}, nil
+	case []interface{}:
+		includes := make([]string, 0, len(x))
+		for _, e := range x {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: %v", includeKey, v)
+			}
+			includes = append(includes, s)
+		}
+		return includes, nil
+	default:
+		return nil, fmt.Errorf("invalid %s: %v", includeKey, v)
+	}
+}
+
+// mergeLayers deep merges the layers, and returns the merged tree and the source
+// file:line of every leaf value.
+func mergeLayers(layers []layer) (map[string]interface{}, map[string]string, error) {
+	tree := make(map[string]interface{})
+	sources := make(map[string]string)
+	for _, l := range layers {
+		lines, err := jsoncfg.KeyLines(l.format, l.data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", l.file, err)
+		}
+		source := func(path string) string {
+			return fmt.Sprintf("%s:%d", l.file, lines[path])
+		}
+		mergeTree(tree, l.tree, "", source, sources)
+	}
+	return tree, sources, nil
+}
+
+func mergeTree(dst, src map[string]interface{}, prefix string, source func(string) string, sources map[string]string) {
+	for k, v := range src {
+		path := joinPath(prefix, k)
+		sm, ok1 := v.(map[string]interface{})
+		dm, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			mergeTree(dm, sm, path, source, sources)
+			continue
+		}
+		for p := range sources {
+			if p == path || strings.HasPrefix(p, path+".") {
+				delete(sources, p)
+			}
+		}
+		dst[k] = v
+		leaves := make(map[string]interface{})
+		flattenValue(leaves, path, v)
+		for p := range leaves {
+			sources[p] = source(p)
+		}
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// loadSections reads and merges the config files, resolves the secret references
+// with read and getenv, and returns the JSON of every module section.
+func loadSections(read readFunc, getenv func(string) (string, bool), file string) (map[string]byteSlice, error) {
+	layers, err := readLayers(read, configFiles(file))
+	if err != nil {
+		return nil, err
+	}
+	tree, _, err := mergeLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	resolver := jsoncfg.Resolver{LookupEnv: getenv, ReadFile: read.ReadFile}
+	sections := make(map[string]byteSlice, len(tree))
+	for k, v := range tree {
+		if v, err = resolver.ResolveTree(v); err != nil {
+			return nil, fmt.Errorf("%s module config: %v", k, err)
+		}
+		if sections[k], err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
+
+// dumpAppConfig writes the running configs with the secret fields redacted,
+// and the source of every leaf value: a config file, env or default.
+func (f *framework) dumpAppConfig(w io.Writer) error {
+	var sources map[string]string
+	if f.options.ConfigFile != "" {
+		layers, err := readLayers(f.readFile, configFiles(f.options.ConfigFile))
+		if err != nil {
+			return err
+		}
+		if _, sources, err = mergeLayers(layers); err != nil {
+			return err
+		}
+	}
+	configs := make(map[string]interface{})
+	for k, v := range f.configs.Interfaces() {
+		x, err := jsoncfg.Redact(v)
+		if err != nil {
+			return err
+		}
+		configs[k] = x
+	}
+	leaves, err := flatten(configs)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(leaves))
+	for p := range leaves {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		source := "default"
+		if s, ok := sources[p]; ok {
+			source = s
+		}
+		if module := strings.SplitN(p, ".", 2); len(module) == 2 {
+			if _, ok := f.envConfigs[module[0]][module[1]]; ok {
+				env, _, _ := f.lookupEnv(p)
+				source = "env " + env
+			}
+		}
+		value, err := json.Marshal(leaves[p])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s = %s\t# %s\n", p, value, source)
+	}
+	return nil
+}
+
+func (f *framework) dumpAppConfigToFile(file string) error {
+	if file == "-" {
+		return f.dumpAppConfig(os.Stdout)
+	}
+	var buf bytes.Buffer
+	if err := f.dumpAppConfig(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0666)
+}
@@ -0,0 +1,130 @@
+package framework
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	tests := []struct {
+		s     string
+		files []string
+	}{
+		{s: "", files: nil},
+		{s: "a.json", files: []string{"a.json"}},
+		{s: "a.json, b.yaml,", files: []string{"a.json", "b.yaml"}},
+	}
+	for i, tt := range tests {
+		if got, want := configFiles(tt.s), tt.files; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"common.json": `{
+	"a": {"Addr": ":6060", "Verbose": 1},
+	"b": {"Addr": ":7070"}
+}`,
+		"base.yaml": `include: common.json
+a:
+  Verbose: 2
+`,
+		"prod.toml": `[b]
+Verbose = 3
+`,
+	})
+	files := []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "prod.toml")}
+	layers, err := readLayers(nil, files)
+	if err != nil {
+		t.Fatalf("read layers: %v", err)
+	}
+	var names []string
+	for _, l := range layers {
+		names = append(names, filepath.Base(l.file))
+	}
+	if got, want := names, []string{"common.json", "base.yaml", "prod.toml"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("layers: got(%v) != want(%v)", got, want)
+	}
+
+	tree, sources, err := mergeLayers(layers)
+	if err != nil {
+		t.Fatalf("merge layers: %v", err)
+	}
+	wantTree := map[string]interface{}{
+		"a": map[string]interface{}{"Addr": ":6060", "Verbose": 2.0},
+		"b": map[string]interface{}{"Addr": ":7070", "Verbose": 3.0},
+	}
+	if got, want := tree, wantTree; !reflect.DeepEqual(got, want) {
+		t.Errorf("tree: got(%v) != want(%v)", got, want)
+	}
+	wantSources := map[string]string{
+		"a.Addr":    filepath.Join(dir, "common.json") + ":2",
+		"a.Verbose": filepath.Join(dir, "base.yaml") + ":3",
+		"b.Addr":    filepath.Join(dir, "common.json") + ":3",
+		"b.Verbose": filepath.Join(dir, "prod.toml") + ":2",
+	}
+	if got, want := sources, wantSources; !reflect.DeepEqual(got, want) {
+		t.Errorf("sources: got(%v) != want(%v)", got, want)
+	}
+
+	var f framework
+	f.options.ConfigFile = strings.Join(files, ",")
+	f.configs.Register("a", &testConfig{})
+	f.configs.Register("b", &testConfig{})
+	f.configs.Register("c", &testConfig{})
+	if f.sections, err = loadAppConfig(&f.configs, nil, nil, f.options.ConfigFile); err != nil {
+		t.Fatalf("load app config: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = f.dumpAppConfig(&buf); err != nil {
+		t.Fatalf("dump app config: %v", err)
+	}
+	want := `a.Addr = ":6060"	# ` + wantSources["a.Addr"] + `
+a.Verbose = 2	# ` + wantSources["a.Verbose"] + `
+b.Addr = ":7070"	# ` + wantSources["b.Addr"] + `
+b.Verbose = 3	# ` + wantSources["b.Verbose"] + `
+c.Addr = ""	# default
+c.Verbose = 0	# default
+`
+	if got := buf.String(); got != want {
+		t.Errorf("dump: got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"a.json": `{"include": ["b.json"]}`,
+		"b.json": `{"include": "a.json"}`,
+	})
+	_, err = readLayers(nil, []string{filepath.Join(dir, "a.json")})
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("read layers: expect include cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package backend_module
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ironzhang/matrix/tlog"
+)
+
+type Role int
+
+const (
+	Anonymous Role = iota
+	Viewer
+	Operator
+)
+
+var roleNames = map[Role]string{
+	Anonymous: "anonymous",
+	Viewer:    "viewer",
+	Operator:  "operator",
+}
+
+func (r Role) String() string {
+	if s, ok := roleNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Role) UnmarshalText(b []byte) error {
+	for k, v := range roleNames {
+		if v == string(b) {
+			*r = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role: %s", b)
+}
+
+type Identity struct {
+	User   string
+	Role   Role
+	Method string
+}
+
+// Authenticator authenticates a request. It returns false if the request has no
+// credentials of its kind, and an error if the credentials are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, bool, error)
+}
+
+type AuthConfig struct {
+	Tokens    []Token         `usage:"Bearer令牌列表"`
+	BasicFile string          `usage:"Basic认证用户文件, 每行格式为user:password:role, password为明文或sha256:<hex>"`
+	CertUsers map[string]Role `usage:"mTLS客户端证书CN到角色的映射"`
+	Routes    map[string]Role `usage:"路径前缀所需的角色, 未配置的路径GET/HEAD需要viewer, 其他方法需要operator"`
+}
+
+type Token struct {
+	Token string `json:",secret" usage:"令牌"`
+	User  string `usage:"用户名"`
+	Role  Role   `usage:"角色, viewer或operator"`
+}
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type tokenAuth []Token
+
+// NewTokenAuth authenticates the requests with the "Authorization: Bearer <token>" header.
+func NewTokenAuth(tokens []Token) Authenticator {
+	return tokenAuth(tokens)
+}
+
+func (a tokenAuth) Authenticate(r *http.Request) (Identity, bool, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return Identity{}, false, nil
+	}
+	token := []byte(strings.TrimPrefix(h, "Bearer "))
+	for _, t := range a {
+		if subtle.ConstantTimeCompare(token, []byte(t.Token)) == 1 {
+			return Identity{User: t.User, Role: t.Role, Method: "token"}, true, nil
+		}
+	}
+	return Identity{}, true, errInvalidCredentials
+}
+
+type basicUser struct {
+	password string
+	role     Role
+}
+
+type basicAuth map[string]basicUser
+
+// NewBasicAuthFile loads the users of HTTP basic auth from file, one user per line
+// in the format user:password:role. The password is plain or sha256:<hex>, the empty
+// lines and the lines starting with # are ignored.
+func NewBasicAuthFile(file string) (Authenticator, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a := make(basicAuth)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: invalid user", file, n)
+		}
+		user, role := fields[0], fields[len(fields)-1]
+		u := basicUser{password: strings.Join(fields[1:len(fields)-1], ":")}
+		if err = u.role.UnmarshalText([]byte(role)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+		a[user] = u
+	}
+	return a, s.Err()
+}
+
+func (a basicAuth) Authenticate(r *http.Request) (Identity, bool, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, false, nil
+	}
+	u, ok := a[user]
+	if !ok || !u.match(password) {
+		return Identity{User: user}, true, errInvalidCredentials
+	}
+	return Identity{User: user, Role: u.role, Method: "basic"}, true, nil
+}
+
+func (u basicUser) match(password string) bool {
+	if strings.HasPrefix(u.password, "sha256:") {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.TrimPrefix(u.password, "sha256:"))) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(u.password)) == 1
+}
+
+type certAuth map[string]Role
+
+// NewCertAuth authenticates the requests by the common names of the verified TLS client certificates.
+func NewCertAuth(users map[string]Role) Authenticator {
+	return certAuth(users)
+}
+
+func (a certAuth) Authenticate(r *http.Request) (Identity, bool, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, false, nil
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := a[cn]
+	if !ok {
+		return Identity{User: cn}, true, errInvalidCredentials
+	}
+	return Identity{User: cn, Role: role, Method: "cert"}, true, nil
+}
+
+func newAuthenticators(c AuthConfig) ([]Authenticator, error) {
+	var as []Authenticator
+	if len(c.Tokens) > 0 {
+		as = append(as, NewTokenAuth(c.Tokens))
+	}
+	if c.BasicFile != "" {
+		a, err := NewBasicAuthFile(c.BasicFile)
+		if err != nil {
+			return nil, fmt.Errorf("load basic auth file: %v", err)
+		}
+		as = append(as, a)
+	}
+	if len(c.CertUsers) > 0 {
+		as = append(as, NewCertAuth(c.CertUsers))
+	}
+	return as, nil
+}
+
+type identityKey struct{}
+
+// RequestIdentity returns the identity of the authenticated request.
+func RequestIdentity(r *http.Request) (Identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// AddAuthenticator adds a custom authenticator, which is tried after the configured ones.
+// It must be called before Run.
+func (m *M) AddAuthenticator(a Authenticator) {
+	m.custom = append(m.custom, a)
+}
+
+// Require sets the role required by the paths with the prefix, it must be called before Run.
+// The routes of the config override it.
+func (m *M) Require(prefix string, role Role) {
+	if m.routes == nil {
+		m.routes = make(map[string]Role)
+	}
+	m.routes[prefix] = role
+}
+
+// requiredRole returns the role of the longest matched route, by default viewer for
+// the read-only methods and operator for the others.
+func (m *M) requiredRole(r *http.Request) Role {
+	var prefix string
+	var role Role
+	match := func(routes map[string]Role) {
+		for p, x := range routes {
+			if strings.HasPrefix(r.URL.Path, p) && len(p) >= len(prefix) {
+				prefix, role = p, x
+			}
+		}
+	}
+	match(m.routes)
+	match(m.config().Auth.Routes)
+	if prefix != "" {
+		return role
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return Viewer
+	default:
+		return Operator
+	}
+}
+
+func (m *M) authenticate(r *http.Request) (Identity, error) {
+	for _, a := range m.authenticators {
+		id, ok, err := a.Authenticate(r)
+		if ok {
+			return id, err
+		}
+	}
+	return Identity{}, nil
+}
+
+// authorize serves the requests whose identities have the required roles,
+// it serves all the requests if no authenticator is configured.
+func (m *M) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(m.authenticators) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := m.authenticate(r)
+		need := m.requiredRole(r)
+		if err == nil && id.Role >= need {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+			return
+		}
+
+		log := tlog.Std().Sugar().With("module", m.Name(), "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path, "user", id.User)
+		if err != nil || id.Method == "" {
+			if err == nil {
+				err = errors.New("no credentials")
+			}
+			log.Warnw("access denied", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer, Basic realm="backend"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		log.Warnw("access denied", "role", id.Role, "required", need)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+}
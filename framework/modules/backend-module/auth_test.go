@@ -0,0 +1,100 @@
+package backend_module
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	file, err := ioutil.TempFile("", "basic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	// sha256("secret")
+	file.WriteString(`# user:password:role
+alice:a:lic:e:operator
+bob:sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b:viewer
+`)
+	file.Close()
+
+	var m M
+	m.Require("/healthz", Anonymous)
+	m.Require("/debug/pprof/", Operator)
+	m.authenticators, err = newAuthenticators(AuthConfig{
+		Tokens:    []Token{{Token: "t1", User: "ci", Role: Viewer}, {Token: "t2", User: "ops", Role: Operator}},
+		BasicFile: file.Name(),
+		CertUsers: map[string]Role{"admin": Operator},
+	})
+	if err != nil {
+		t.Fatalf("new authenticators: %v", err)
+	}
+
+	var user string
+	h := m.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := RequestIdentity(r)
+		user = id.User
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		basic  [2]string
+		cn     string
+		code   int
+		user   string
+	}{
+		{method: "GET", path: "/healthz", code: 200},
+		{method: "GET", path: "/dashboard/configs", code: 401},
+		{method: "GET", path: "/dashboard/configs", token: "bad", code: 401},
+		{method: "GET", path: "/dashboard/configs", token: "t1", code: 200, user: "ci"},
+		{method: "PUT", path: "/dashboard/configs/a", token: "t1", code: 403},
+		{method: "PUT", path: "/dashboard/configs/a", token: "t2", code: 200, user: "ops"},
+		{method: "GET", path: "/debug/pprof/profile", token: "t1", code: 403},
+		{method: "GET", path: "/debug/pprof/profile", basic: [2]string{"alice", "a:lic:e"}, code: 200, user: "alice"},
+		{method: "GET", path: "/dashboard/configs", basic: [2]string{"bob", "secret"}, code: 200, user: "bob"},
+		{method: "GET", path: "/dashboard/configs", basic: [2]string{"bob", "bad"}, code: 401},
+		{method: "PUT", path: "/dashboard/log/level", basic: [2]string{"bob", "secret"}, code: 403},
+		{method: "PUT", path: "/dashboard/log/level", cn: "admin", code: 200, user: "admin"},
+		{method: "GET", path: "/dashboard/configs", cn: "guest", code: 401},
+	}
+	for i, tt := range tests {
+		user = ""
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.basic[0] != "" {
+			r.SetBasicAuth(tt.basic[0], tt.basic[1])
+		}
+		if tt.cn != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.cn}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.code; got != want {
+			t.Errorf("tests[%d]: %s %s: code: got(%d) != want(%d)", i, tt.method, tt.path, got, want)
+		}
+		if got, want := user, tt.user; got != want {
+			t.Errorf("tests[%d]: %s %s: user: got(%q) != want(%q)", i, tt.method, tt.path, got, want)
+		}
+	}
+}
+
+func TestAuthorizeDisabled(t *testing.T) {
+	var m M
+	var served bool
+	h := m.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/dashboard/configs/a", nil))
+	if !served {
+		t.Errorf("request not served without authenticators")
+	}
+}
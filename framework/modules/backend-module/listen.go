@@ -0,0 +1,215 @@
+package backend_module
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	listen_mux "github.com/ironzhang/matrix/netutils/listen-mux"
+)
+
+const unixScheme = "unix://"
+
+type TLSConfig struct {
+	CertFile     string `json:",writeable" usage:"证书文件, 为空时不启用TLS"`
+	KeyFile      string `json:",writeable" usage:"私钥文件"`
+	ClientCAFile string `json:",writeable" usage:"客户端CA证书文件, 不为空时校验客户端证书(mTLS), 未提供证书的客户端仍可使用其他认证方式"`
+}
+
+// splitAddrs splits the comma separated addresses into the tcp addresses and the unix socket paths.
+func splitAddrs(addrs string) (tcps []string, unixes []string, err error) {
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		switch {
+		case addr == "":
+		case strings.HasPrefix(addr, unixScheme):
+			path := strings.TrimPrefix(addr, unixScheme)
+			if path == "" {
+				return nil, nil, fmt.Errorf("%s: empty unix socket path", addr)
+			}
+			unixes = append(unixes, path)
+		default:
+			tcps = append(tcps, addr)
+		}
+	}
+	if len(tcps) == 0 && len(unixes) == 0 {
+		return nil, nil, errors.New("no listen address")
+	}
+	return tcps, unixes, nil
+}
+
+func parseSocketMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid socket mode %q", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// listen listens on the comma separated addresses, unix:///path for the unix sockets,
+// the files of which are created with mode.
+func listen(addrs string, mode os.FileMode) (net.Listener, error) {
+	tcps, unixes, err := splitAddrs(addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+	if len(tcps) > 0 {
+		ln, err := listen_mux.Listen("tcp", tcps, 0)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	for _, path := range unixes {
+		if err = removeStaleSocket(path); err != nil {
+			closeAll()
+			return nil, err
+		}
+		ln, err := listenUnix(path, mode)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	network := "tcp"
+	if len(tcps) == 0 {
+		network = "unix"
+	}
+	return listen_mux.NewListener(network, listeners, 0), nil
+}
+
+// listenUnix listens on the unix socket path with mode. The socket is created in a
+// private directory next to path, and moved into place after its mode is set, so it
+// never has wider permissions than mode, without changing the umask of the process.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	ln.SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: ln, path: path}, nil
+}
+
+// unixListener removes the socket file when it is closed.
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// removeStaleSocket removes the socket file left by a process which did not exit
+// cleanly, the other files are kept and fail the listen.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s: file exists and is not a socket", path)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return fmt.Errorf("%s: socket in use", path)
+	}
+	return os.Remove(path)
+}
+
+// loadTLS loads the certificate and the client CAs, it returns nil if TLS is disabled.
+func loadTLS(c TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAFile != "" {
+			return nil, errors.New("client CA file without certificate")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if c.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("load client CA: no certificate found in %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// reloadTLS reloads the certificate and the client CAs used by the new connections.
+// TLS can not be enabled or disabled without restarting.
+func (m *M) reloadTLS(c TLSConfig) error {
+	m.mu.Lock()
+	ln := m.ln
+	m.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	config, err := loadTLS(c)
+	if err != nil {
+		return err
+	}
+	if (config == nil) != (m.tls.Load() == nil) {
+		return errors.New("enable or disable TLS requires restart")
+	}
+	if config != nil {
+		m.tls.Store(config)
+	}
+	return nil
+}
+
+// serverTLS returns the config of the TLS listener, which takes the current certificate
+// and client CAs for every handshake.
+func (m *M) serverTLS() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return m.tls.Load().(*tls.Config), nil
+		},
+	}
+}
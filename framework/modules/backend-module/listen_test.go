@@ -0,0 +1,329 @@
+package backend_module
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/matrix/framework"
+)
+
+func TestSplitAddrs(t *testing.T) {
+	tests := []struct {
+		addrs  string
+		tcps   []string
+		unixes []string
+		err    bool
+	}{
+		{addrs: ":6060", tcps: []string{":6060"}},
+		{addrs: "127.0.0.1:6060, unix:///tmp/a.sock", tcps: []string{"127.0.0.1:6060"}, unixes: []string{"/tmp/a.sock"}},
+		{addrs: "unix:///tmp/a.sock,unix:///tmp/b.sock", unixes: []string{"/tmp/a.sock", "/tmp/b.sock"}},
+		{addrs: "", err: true},
+		{addrs: "unix://", err: true},
+	}
+	for i, tt := range tests {
+		tcps, unixes, err := splitAddrs(tt.addrs)
+		if (err != nil) != tt.err {
+			t.Errorf("tests[%d]: error: %v", i, err)
+			continue
+		}
+		if strings.Join(tcps, ",") != strings.Join(tt.tcps, ",") || strings.Join(unixes, ",") != strings.Join(tt.unixes, ",") {
+			t.Errorf("tests[%d]: got(%v, %v) != want(%v, %v)", i, tcps, unixes, tt.tcps, tt.unixes)
+		}
+	}
+}
+
+func get(t *testing.T, c *http.Client, url string) string {
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatalf("get %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// a stale socket file is removed
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen("127.0.0.1:0,unix://"+path, 0600)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	if _, err = listen("unix://"+path, 0600); err == nil {
+		t.Errorf("listen on the socket in use succeeded")
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("mode: got(%v) != want(%v)", got, want)
+	}
+
+	// the files which are not sockets are kept
+	file := filepath.Join(dir, "admin.txt")
+	if err = ioutil.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = listen("unix://"+file, 0600); err == nil {
+		t.Errorf("listen on the regular file succeeded")
+	}
+	if data, err := ioutil.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("regular file: %q, %v", data, err)
+	}
+
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("pong")) }))
+	tcp := strings.Split(ln.Addr().String(), ",")[0]
+	if got := get(t, http.DefaultClient, "http://"+tcp+"/"); got != "pong" {
+		t.Errorf("tcp: got(%q) != want(%q)", got, "pong")
+	}
+	if got := get(t, unixClient(path), "http://unix/"); got != "pong" {
+		t.Errorf("unix: got(%q) != want(%q)", got, "pong")
+	}
+
+	// the socket file is removed on close, and no temp file is left
+	other := filepath.Join(dir, "other.sock")
+	ln2, err := listen("unix://"+other, 0640)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if fi, err = os.Stat(other); err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0640); got != want {
+		t.Errorf("mode: got(%v) != want(%v)", got, want)
+	}
+	ln2.Close()
+	if _, err = os.Lstat(other); !os.IsNotExist(err) {
+		t.Errorf("socket file after close: %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		if name := fi.Name(); name != "admin.sock" && name != "admin.txt" {
+			t.Errorf("file left: %s", name)
+		}
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCert(t *testing.T, ca *testCA, cn string) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, signer := tmpl, key
+	if ca == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	kder, _ := x509.MarshalECPrivateKey(key)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestListenTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caCert, caKey, caPEM, _ := newCert(t, nil, "ca")
+	ca := &testCA{cert: caCert, key: caKey}
+	_, _, cert1, key1 := newCert(t, ca, "server1")
+	_, _, cert2, key2 := newCert(t, ca, "server2")
+	_, _, clientCert, clientKey := newCert(t, ca, "alice")
+	c := TLSConfig{
+		CertFile:     writeFile(t, dir, "server.crt", cert1),
+		KeyFile:      writeFile(t, dir, "server.key", key1),
+		ClientCAFile: writeFile(t, dir, "ca.crt", caPEM),
+	}
+
+	m := &M{}
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := RequestIdentity(r)
+		w.Write([]byte(id.User))
+	})
+	m.authenticators = []Authenticator{NewCertAuth(map[string]Role{"alice": Viewer})}
+	config, err := loadTLS(c)
+	if err != nil {
+		t.Fatalf("load tls: %v", err)
+	}
+	if m.ln, err = listen("127.0.0.1:0", 0600); err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	m.tls.Store(config)
+	m.ln = tls.NewListener(m.ln, m.serverTLS())
+	defer m.ln.Close()
+	go http.Serve(m.ln, m.authorize(&m.ServeMux))
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverName := func(cs *tls.ConnectionState) string {
+		return cs.PeerCertificates[0].Subject.CommonName
+	}
+	url := "https://" + m.Addr().String() + "/"
+	request := func(certs []tls.Certificate) (string, int, string) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certs},
+		}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return serverName(resp.TLS), resp.StatusCode, string(body)
+	}
+
+	if server, code, user := request([]tls.Certificate{pair}); server != "server1" || code != http.StatusOK || user != "alice" {
+		t.Errorf("client cert: got(%s, %d, %q) != want(server1, 200, alice)", server, code, user)
+	}
+	if _, code, _ := request(nil); code != http.StatusUnauthorized {
+		t.Errorf("no client cert: got(%d) != want(%d)", code, http.StatusUnauthorized)
+	}
+
+	writeFile(t, dir, "server.crt", cert2)
+	writeFile(t, dir, "server.key", key2)
+	if err = m.reloadTLS(c); err != nil {
+		t.Fatalf("reload tls: %v", err)
+	}
+	if server, _, _ := request([]tls.Certificate{pair}); server != "server2" {
+		t.Errorf("reloaded: got(%s) != want(server2)", server)
+	}
+	if err = m.reloadTLS(TLSConfig{}); err == nil {
+		t.Errorf("reload disabled tls succeeded")
+	}
+}
+
+func TestReloadTLSPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caCert, caKey, caPEM, _ := newCert(t, nil, "ca")
+	ca := &testCA{cert: caCert, key: caKey}
+	_, _, cert1, key1 := newCert(t, ca, "server1")
+	_, _, cert2, key2 := newCert(t, ca, "server2")
+	c := &C{Addr: "127.0.0.1:0", SocketMode: "0600", TLS: TLSConfig{
+		CertFile: writeFile(t, dir, "server1.crt", cert1),
+		KeyFile:  writeFile(t, dir, "server1.key", key1),
+	}}
+	m := New(c)
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	app := framework.New(framework.AppOptions{})
+	if err = app.Register(m, nil, c); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err = app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer app.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, DisableKeepAlives: true}}
+	serverName := func() string {
+		resp, err := client.Get("https://" + m.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if got := serverName(); got != "server1" {
+		t.Errorf("server: got(%s) != want(server1)", got)
+	}
+
+	v, _ := app.Configs().GetValue(m.Name())
+	if paths, err := v.ReadonlyChanges(map[string]interface{}{"TLS": map[string]interface{}{"CertFile": "x"}}); err != nil || len(paths) > 0 {
+		t.Errorf("readonly changes: %v, %v", paths, err)
+	}
+	files := map[string]interface{}{
+		"CertFile": writeFile(t, dir, "server2.crt", cert2),
+		"KeyFile":  writeFile(t, dir, "server2.key", key2),
+	}
+	if err = v.Store(map[string]interface{}{"TLS": files}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if got, want := v.Snapshot().(*C).TLS.CertFile, files["CertFile"]; got != want {
+		t.Errorf("cert file: got(%s) != want(%s)", got, want)
+	}
+	if got := serverName(); got != "server2" {
+		t.Errorf("reloaded server: got(%s) != want(server2)", got)
+	}
+}
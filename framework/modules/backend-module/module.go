@@ -2,8 +2,12 @@ package backend_module
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ironzhang/matrix/framework"
 	"github.com/ironzhang/matrix/httputils"
@@ -11,41 +15,157 @@ import (
 )
 
 var Config = &C{
-	Addr:    ":6060",
-	Verbose: 0,
+	Addr:       ":6060",
+	SocketMode: "0600",
+	Verbose:    0,
 }
 
-var Module = &M{}
+var Module = New(Config)
 
 func init() {
 	framework.Register(Module, nil, Config)
 }
 
 type C struct {
-	Addr    string `json:",readonly"`
-	Verbose int64  `json:",writeable"`
+	Addr       string     `json:",readonly" usage:"监听地址, 多个地址以逗号分隔, unix:///path表示unix socket"`
+	SocketMode string     `json:",readonly" usage:"unix socket文件权限, 八进制"`
+	TLS        TLSConfig  `json:",writeable" usage:"TLS配置, 重新加载时更新证书, 启用或关闭TLS需要重启"`
+	Verbose    int64      `json:",writeable" usage:"请求日志详细级别"`
+	Auth       AuthConfig `json:",readonly" usage:"管理接口认证, 未配置任何认证方式时不认证"`
+
+	m *M
 }
 
 func (c *C) Reload() error {
-	log := tlog.Std().Sugar().With("module", Module.Name())
+	log := tlog.Std().Sugar().With("module", c.m.Name())
 	log.Debug("reload")
-	Module.verbose.Store(c.Verbose)
+	if err := c.m.reloadTLS(c.TLS); err != nil {
+		log.Errorw("reload tls", "error", err)
+		return err
+	}
+	c.m.verbose.Store(c.Verbose)
 	return nil
 }
 
 type M struct {
 	http.ServeMux
 	verbose httputils.Verbose
+	mu      sync.Mutex
 	ln      net.Listener
+	closed  bool // ln is closed by the last Run, the next Run listens again
+	tls     atomic.Value
+	serving int32
+
+	authenticators []Authenticator
+	custom         []Authenticator
+	routes         map[string]Role
+}
+
+// New returns a backend module with the config c, which must be registered with the
+// module, e.g. framework.Register(m, nil, c). The modules which depend on the backend
+// module serve on Module by default.
+func New(c *C) *M {
+	m := &M{}
+	c.m = m
+	return m
 }
 
 func (m *M) Name() string {
 	return "backend-module"
 }
 
+var errNoConfig = errors.New("config not registered")
+
+// config returns the current config registered with the module, nil if the module is
+// registered without one, which is rejected by Init and CheckConfig.
+func (m *M) config() *C {
+	c, _ := framework.Configs().GetSnapshot(m.Name())
+	x, _ := c.(*C)
+	return x
+}
+
 func (m *M) Init() (err error) {
-	m.verbose.Store(Config.Verbose)
-	if m.ln, err = net.Listen("tcp", Config.Addr); err != nil {
+	c := m.config()
+	if c == nil {
+		return errNoConfig
+	}
+	m.verbose.Store(c.Verbose)
+	if m.authenticators, err = newAuthenticators(c.Auth); err != nil {
+		return err
+	}
+	m.authenticators = append(m.authenticators, m.custom...)
+	config, err := loadTLS(c.TLS)
+	if err != nil {
+		return err
+	}
+	if config != nil {
+		m.tls.Store(config)
+	}
+	return m.listen(c)
+}
+
+// listen listens on the addresses of c, with TLS if it is enabled by Init.
+func (m *M) listen(c *C) error {
+	mode, err := parseSocketMode(c.SocketMode)
+	if err != nil {
+		return err
+	}
+	ln, err := listen(c.Addr, mode)
+	if err != nil {
+		return err
+	}
+	if m.tls.Load() != nil {
+		ln = tls.NewListener(ln, m.serverTLS())
+	}
+	m.mu.Lock()
+	m.ln, m.closed = ln, false
+	m.mu.Unlock()
+	return nil
+}
+
+// listener returns the listener to serve on, which is listened again if it is closed
+// by the last Run, e.g. the module is restarted by the restart policy.
+func (m *M) listener() (net.Listener, error) {
+	m.mu.Lock()
+	ln, closed := m.ln, m.closed
+	m.mu.Unlock()
+	if !closed {
+		return ln, nil
+	}
+	if err := m.listen(m.config()); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ln, nil
+}
+
+// CheckConfig checks the listen addresses without listening on them, and loads the TLS and auth config.
+func (m *M) CheckConfig() error {
+	c := m.config()
+	if c == nil {
+		return errNoConfig
+	}
+	tcps, _, err := splitAddrs(c.Addr)
+	if err != nil {
+		return err
+	}
+	for _, addr := range tcps {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		if _, err = net.LookupPort("tcp", port); err != nil {
+			return err
+		}
+	}
+	if _, err = parseSocketMode(c.SocketMode); err != nil {
+		return err
+	}
+	if _, err = loadTLS(c.TLS); err != nil {
+		return err
+	}
+	if _, err = newAuthenticators(c.Auth); err != nil {
 		return err
 	}
 	return nil
@@ -55,14 +175,45 @@ func (m *M) Fini() error {
 	return nil
 }
 
-func (m *M) Run(ctx context.Context) {
+func (m *M) Run(ctx context.Context) error {
+	ln, err := m.listener()
+	if err != nil {
+		return err
+	}
 	go func() {
 		<-ctx.Done()
-		m.ln.Close()
+		ln.Close()
 	}()
 
-	log := tlog.Std().Sugar().With("module", m.Name(), "addr", Config.Addr)
+	log := tlog.Std().Sugar().With("module", m.Name(), "addr", m.config().Addr)
 	log.Info("start")
-	http.Serve(m.ln, httputils.NewVerboseHandler(&m.verbose, nil, &m.ServeMux))
+	atomic.StoreInt32(&m.serving, 1)
+	err = http.Serve(ln, httputils.NewVerboseHandler(&m.verbose, nil, m.authorize(&m.ServeMux)))
+	atomic.StoreInt32(&m.serving, 0)
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
 	log.Info("stop")
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// Addr returns the listen address after Init.
+func (m *M) Addr() net.Addr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ln.Addr()
+}
+
+func (m *M) CheckHealth(ctx context.Context) error {
+	if atomic.LoadInt32(&m.serving) == 0 {
+		return errors.New("listener not serving")
+	}
+	return nil
+}
+
+func (m *M) CheckReady(ctx context.Context) error {
+	return m.CheckHealth(ctx)
 }
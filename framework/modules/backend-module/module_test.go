@@ -1,7 +1,13 @@
 package backend_module
 
 import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ironzhang/matrix/framework"
 )
@@ -9,3 +15,116 @@ import (
 func TestModule(t *testing.T) {
 	framework.Main()
 }
+
+func TestModuleApp(t *testing.T) {
+	app := framework.New(framework.AppOptions{
+		Args: []string{"-config-file", "backend.json"},
+		ReadFile: func(name string) ([]byte, error) {
+			if name == "backend.json" {
+				return []byte(`{"backend-module": {"Addr": "127.0.0.1:0", "Verbose": 1}}`), nil
+			}
+			return nil, os.ErrNotExist
+		},
+	})
+	c := &C{Addr: ":6060", SocketMode: "0600"}
+	m := New(c)
+	if err := app.Register(m, nil, c); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	m.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("pong")) })
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer app.Stop()
+
+	for i := 0; m.CheckHealth(context.Background()) != nil; i++ {
+		if i >= 100 {
+			t.Fatalf("backend not serving")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := http.Get("http://" + m.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if got, want := string(body), "pong"; got != want {
+		t.Errorf("body: got(%q) != want(%q)", got, want)
+	}
+
+	v, _ := app.Configs().GetValue(m.Name())
+	if err = v.Store(map[string]interface{}{"Verbose": 2}); err != nil {
+		t.Errorf("store: %v", err)
+	}
+	if got, want := m.verbose.Load(), int64(2); got != want {
+		t.Errorf("verbose: got(%d) != want(%d)", got, want)
+	}
+	if Config.Addr != ":6060" || Config.Verbose != 0 || Module.verbose.Load() != 0 {
+		t.Errorf("package config or module changed: %+v", Config)
+	}
+
+	if err = app.Stop(); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+	if m.CheckHealth(context.Background()) == nil {
+		t.Errorf("backend still serving after stop")
+	}
+}
+
+func TestModuleNoConfig(t *testing.T) {
+	app := framework.New(framework.AppOptions{})
+	m := New(&C{})
+	if err := app.Register(m, nil, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	err := app.Start(context.Background())
+	if err == nil {
+		app.Stop()
+	}
+	if err == nil || !strings.Contains(err.Error(), errNoConfig.Error()) {
+		t.Errorf("start: got(%v) != want(%v)", err, errNoConfig)
+	}
+}
+
+func TestModuleRestart(t *testing.T) {
+	app := framework.New(framework.AppOptions{})
+	c := &C{Addr: "127.0.0.1:0", SocketMode: "0600"}
+	m := New(c)
+	if err := app.Register(m, nil, c); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	app.SetRestartPolicy(m.Name(), framework.RestartPolicy{Policy: framework.RestartOnFailure, MinBackoff: 10 * time.Millisecond})
+	m.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("pong")) })
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer app.Stop()
+	for i := 0; m.CheckHealth(context.Background()) != nil; i++ {
+		if i >= 100 {
+			t.Fatalf("backend not serving")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the run fails on the closed listener, the restarted run listens again
+	m.mu.Lock()
+	ln := m.ln
+	m.mu.Unlock()
+	ln.Close()
+	for i := 0; m.Addr().String() == ln.Addr().String() || m.CheckHealth(context.Background()) != nil; i++ {
+		if i >= 100 {
+			t.Fatalf("backend not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := http.Get("http://" + m.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if got, want := string(body), "pong"; got != want {
+		t.Errorf("body: got(%q) != want(%q)", got, want)
+	}
+}
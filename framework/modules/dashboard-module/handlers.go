@@ -2,39 +2,72 @@ package dashboard_module
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ironzhang/matrix/codes"
 	"github.com/ironzhang/matrix/errs"
+	"github.com/ironzhang/matrix/framework"
 	"github.com/ironzhang/matrix/framework/pkg/model"
+	"github.com/ironzhang/matrix/jsoncfg"
 	"github.com/ironzhang/matrix/restful"
+	"github.com/ironzhang/matrix/tlog"
 )
 
 type handlers struct {
+	mu      sync.Mutex // serializes the config updates, so a failed save restores the previous value
 	configs *model.Values
+	flags   *model.Values
+	options func() map[string][]framework.Option
+	module  func(string) ([]framework.Option, bool)
+	runners func() []framework.RunnerStatus
+	modules func() []framework.ModuleStatus
+	persist func() bool
+	save    func(module string, prev interface{}) error
+	diff    func() ([]framework.ConfigDiff, error)
+	history *history
 }
 
 func (h *handlers) Register(m *restful.ServeMux) error {
 	apis := []restful.API{
 		{"GET", "/dashboard/configs", h.GetConfigs},
+		{"GET", "/dashboard/configs/diff", h.GetConfigsDiff},
 		{"GET", "/dashboard/configs/:module", h.GetModuleConfig},
 		{"PUT", "/dashboard/configs/:module", h.PutModuleConfig},
+		{"GET", "/dashboard/history/:module", h.GetHistory},
+		{"POST", "/dashboard/history/:module/:revision/rollback", h.Rollback},
+		{"GET", "/dashboard/options", h.GetOptions},
+		{"GET", "/dashboard/options/:module", h.GetModuleOptions},
+		{"PUT", "/dashboard/options/:module", h.PutModuleOptions},
+		{"GET", "/dashboard/runners", h.GetRunners},
+		{"GET", "/dashboard/modules", h.GetModules},
 	}
 	return restful.Register(m, apis)
 }
 
-func (h *handlers) GetConfigs(ctx context.Context, values url.Values, req interface{}, resp *interface{}) error {
-	*resp = h.configs.Interfaces()
+func (h *handlers) GetConfigs(ctx context.Context, values url.Values, req interface{}, resp *map[string]interface{}) (err error) {
+	*resp = make(map[string]interface{})
+	for k, v := range h.configs.Snapshots() {
+		if (*resp)[k], err = jsoncfg.Redact(v); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (h *handlers) GetModuleConfig(ctx context.Context, values url.Values, req interface{}, resp *interface{}) error {
+func (h *handlers) GetModuleConfig(ctx context.Context, values url.Values, req interface{}, resp *interface{}) (err error) {
 	module := values.Get(":module")
-	c, ok := h.configs.GetInterface(module)
+	c, ok := h.configs.GetSnapshot(module)
 	if !ok {
 		return errs.NotFound("configs", module)
 	}
-	*resp = c
-	return nil
+	*resp, err = jsoncfg.Redact(c)
+	return err
 }
 
 func (h *handlers) PutModuleConfig(ctx context.Context, values url.Values, req map[string]interface{}, resp *interface{}) (err error) {
@@ -43,12 +76,158 @@ func (h *handlers) PutModuleConfig(ctx context.Context, values url.Values, req m
 	if !ok {
 		return errs.NotFound("configs", module)
 	}
+	jsoncfg.DropRedacted(req, v.Snapshot())
+	if err = h.update(ctx, module, v, req, 0); err != nil {
+		return err
+	}
+	*resp, err = jsoncfg.Redact(v.Snapshot())
+	return err
+}
+
+func (h *handlers) update(ctx context.Context, module string, v *model.Value, req map[string]interface{}, rollback int) (err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old := v.Snapshot()
+	prev, err := snapshot(old)
+	if err != nil {
+		return err
+	}
 	if err = v.Store(req); err != nil {
+		return restful.Errorf(http.StatusBadRequest, codes.InvalidParam, "store config: %v", err)
+	}
+
+	// the change is rolled back if it can not be saved
+	log := tlog.WithContext(ctx).Sugar().With("module", module)
+	if h.persist() {
+		if err = h.save(module, old); err != nil {
+			log.Errorw("save app config", "error", err)
+			var x map[string]interface{}
+			if e := json.Unmarshal(prev, &x); e != nil {
+				return e
+			}
+			if e := v.Store(x); e != nil {
+				log.Errorw("restore config", "error", e)
+			}
+			return restful.Errorf(http.StatusInternalServerError, codes.Internal, "save app config: %v", err)
+		}
+	}
+
+	value, err := snapshot(v.Snapshot())
+	if err != nil {
+		return err
+	}
+	addr, user := caller(ctx)
+	rev := h.history.add(Revision{
+		Module:   module,
+		Time:     time.Now(),
+		Caller:   addr,
+		User:     user,
+		Rollback: rollback,
+		Prev:     prev,
+		Value:    value,
+	})
+	cfg, err := jsoncfg.Redact(v.Snapshot())
+	if err != nil {
+		return err
+	}
+	log.Infow("config changed", "revision", rev.ID, "caller", addr, "user", user, "rollback", rollback, "config", cfg)
+	return nil
+}
+
+func (h *handlers) GetHistory(ctx context.Context, values url.Values, req interface{}, resp *[]Revision) (err error) {
+	module := values.Get(":module")
+	v, ok := h.configs.GetValue(module)
+	if !ok {
+		return errs.NotFound("configs", module)
+	}
+	revs := h.history.list(module)
+	for i := range revs {
+		if revs[i].Prev, err = redactRaw(revs[i].Prev, v.Snapshot()); err != nil {
+			return err
+		}
+		if revs[i].Value, err = redactRaw(revs[i].Value, v.Snapshot()); err != nil {
+			return err
+		}
+	}
+	*resp = revs
+	return nil
+}
+
+// Rollback restores the config of module to the value before the revision.
+func (h *handlers) Rollback(ctx context.Context, values url.Values, req interface{}, resp *interface{}) (err error) {
+	module := values.Get(":module")
+	v, ok := h.configs.GetValue(module)
+	if !ok {
+		return errs.NotFound("configs", module)
+	}
+	id, err := strconv.Atoi(values.Get(":revision"))
+	if err != nil {
+		return restful.Errorf(http.StatusBadRequest, codes.InvalidParam, "invalid revision: %s", values.Get(":revision"))
+	}
+	rev, ok := h.history.get(module, id)
+	if !ok {
+		return errs.NotFound("revision", id)
+	}
+	var prev map[string]interface{}
+	if err = json.Unmarshal(rev.Prev, &prev); err != nil {
 		return err
 	}
-	if err = v.Reload(); err != nil {
+	if err = h.update(ctx, module, v, prev, id); err != nil {
 		return err
 	}
-	*resp = v.Load()
+	*resp, err = jsoncfg.Redact(v.Snapshot())
+	return err
+}
+
+func (h *handlers) GetConfigsDiff(ctx context.Context, values url.Values, req interface{}, resp *[]framework.ConfigDiff) (err error) {
+	if *resp, err = h.diff(); err != nil {
+		return restful.Errorf(http.StatusInternalServerError, codes.Internal, "diff app config: %v", err)
+	}
+	return nil
+}
+
+func (h *handlers) GetOptions(ctx context.Context, values url.Values, req interface{}, resp *map[string][]framework.Option) error {
+	*resp = h.options()
+	return nil
+}
+
+func (h *handlers) GetModuleOptions(ctx context.Context, values url.Values, req interface{}, resp *[]framework.Option) error {
+	module := values.Get(":module")
+	opts, ok := h.module(module)
+	if !ok {
+		return errs.NotFound("options", module)
+	}
+	*resp = opts
+	return nil
+}
+
+func (h *handlers) PutModuleOptions(ctx context.Context, values url.Values, req map[string]interface{}, resp *[]framework.Option) (err error) {
+	module := values.Get(":module")
+	v, ok := h.flags.GetValue(module)
+	if !ok {
+		return errs.NotFound("options", module)
+	}
+	paths, err := v.ReadonlyChanges(req)
+	if err != nil {
+		return restful.Errorf(http.StatusBadRequest, codes.InvalidParam, "check options: %v", err)
+	}
+	if len(paths) > 0 {
+		return restful.Errorf(http.StatusForbidden, codes.NotAllowed, "readonly options: %s", strings.Join(paths, ", "))
+	}
+	if err = v.Update(req); err != nil {
+		return restful.Errorf(http.StatusBadRequest, codes.InvalidParam, "update options: %v", err)
+	}
+	*resp, _ = h.module(module)
+	return nil
+}
+
+func (h *handlers) GetRunners(ctx context.Context, values url.Values, req interface{}, resp *[]framework.RunnerStatus) error {
+	*resp = h.runners()
+	return nil
+}
+
+func (h *handlers) GetModules(ctx context.Context, values url.Values, req interface{}, resp *[]framework.ModuleStatus) error {
+	*resp = h.modules()
 	return nil
 }
@@ -0,0 +1,158 @@
+package dashboard_module
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ironzhang/matrix/framework"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/framework/pkg/model"
+)
+
+type testModule struct {
+	name string
+}
+
+func (m *testModule) Name() string { return m.name }
+func (m *testModule) Init() error  { return nil }
+func (m *testModule) Fini() error  { return nil }
+
+type testOptions struct {
+	Addr  string `json:"addr" usage:"listen address"`
+	Level int    `json:"level,writeable" usage:"log level"`
+}
+
+func startApp(t *testing.T, ms ...framework.Module) (*framework.App, *backend_module.M) {
+	app := framework.New(framework.AppOptions{})
+	bc := &backend_module.C{Addr: "127.0.0.1:0", SocketMode: "0600"}
+	b := backend_module.New(bc)
+	if err := app.Register(b, nil, bc); err != nil {
+		t.Fatalf("register backend: %v", err)
+	}
+	if err := app.Register(New(b), nil, &C{HistorySize: 10}); err != nil {
+		t.Fatalf("register dashboard: %v", err)
+	}
+	for _, m := range ms {
+		if err := app.Register(m, &testOptions{Addr: ":80", Level: 1}, nil); err != nil {
+			t.Fatalf("register %s: %v", m.Name(), err)
+		}
+	}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	return app, b
+}
+
+func do(t *testing.T, method, url string, body interface{}, resp interface{}) int {
+	var b bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&b).Encode(body)
+	}
+	req, _ := http.NewRequest(method, url, &b)
+	req.Header.Set("Content-Type", "application/json")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusOK && resp != nil {
+		if err = json.NewDecoder(r.Body).Decode(resp); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, url, err)
+		}
+	}
+	return r.StatusCode
+}
+
+func optionValues(opts []framework.Option) map[string]string {
+	m := make(map[string]string)
+	for _, o := range opts {
+		m[o.Name] = o.Value
+	}
+	return m
+}
+
+func TestPutModuleOptions(t *testing.T) {
+	app, b := startApp(t, &testModule{name: "a"})
+	defer app.Stop()
+
+	url := "http://" + b.Addr().String() + "/dashboard/options/a"
+	tests := []struct {
+		req   map[string]interface{}
+		code  int
+		level string
+		addr  string
+	}{
+		{req: map[string]interface{}{"level": 5}, code: http.StatusOK, level: "5", addr: ":80"},
+		{req: map[string]interface{}{"addr": ":8080"}, code: http.StatusForbidden, level: "5", addr: ":80"},
+		{req: map[string]interface{}{"level": "x"}, code: http.StatusBadRequest, level: "5", addr: ":80"},
+	}
+	for i, tt := range tests {
+		var put []framework.Option
+		if code := do(t, "PUT", url, tt.req, &put); code != tt.code {
+			t.Errorf("tests[%d]: put: code: got(%d) != want(%d)", i, code, tt.code)
+		}
+		if tt.code == http.StatusOK {
+			if got := optionValues(put)["a.level"]; got != tt.level {
+				t.Errorf("tests[%d]: put: level: got(%s) != want(%s)", i, got, tt.level)
+			}
+		}
+
+		var get []framework.Option
+		if code := do(t, "GET", url, nil, &get); code != http.StatusOK {
+			t.Fatalf("tests[%d]: get: code(%d)", i, code)
+		}
+		values := optionValues(get)
+		if values["a.level"] != tt.level || values["a.addr"] != tt.addr {
+			t.Errorf("tests[%d]: get: level(%s), addr(%s) != want level(%s), addr(%s)", i, values["a.level"], values["a.addr"], tt.level, tt.addr)
+		}
+	}
+}
+
+type testConfig struct {
+	Level int `json:",writeable"`
+}
+
+func TestUpdateSaveFailure(t *testing.T) {
+	tests := []struct {
+		save  error
+		level int
+		revs  int
+	}{
+		{save: nil, level: 2, revs: 1},
+		{save: errors.New("disk full"), level: 1, revs: 0},
+	}
+	for i, tt := range tests {
+		var configs model.Values
+		configs.Register("a", &testConfig{Level: 1})
+		configs.Seal()
+		var saved interface{}
+		h := &handlers{
+			configs: &configs,
+			persist: func() bool { return true },
+			save: func(module string, prev interface{}) error {
+				saved = prev
+				return tt.save
+			},
+			history: &history{size: func() int { return 10 }},
+		}
+
+		v, _ := configs.GetValue("a")
+		err := h.update(context.Background(), "a", v, map[string]interface{}{"Level": 2}, 0)
+		if (err != nil) != (tt.save != nil) {
+			t.Errorf("tests[%d]: update: %v", i, err)
+		}
+		if got, want := saved, (&testConfig{Level: 1}); *got.(*testConfig) != *want {
+			t.Errorf("tests[%d]: saved prev: got(%+v) != want(%+v)", i, got, want)
+		}
+		if got, want := v.Snapshot().(*testConfig).Level, tt.level; got != want {
+			t.Errorf("tests[%d]: level: got(%d) != want(%d)", i, got, want)
+		}
+		if got, want := len(h.history.list("a")), tt.revs; got != want {
+			t.Errorf("tests[%d]: revisions: got(%d) != want(%d)", i, got, want)
+		}
+	}
+}
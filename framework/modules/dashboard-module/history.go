@@ -0,0 +1,98 @@
+package dashboard_module
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/matrix/context-value"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/jsoncfg"
+)
+
+type Revision struct {
+	ID       int
+	Module   string
+	Time     time.Time
+	Caller   string
+	User     string `json:",omitempty"`
+	Rollback int    `json:",omitempty"`
+	Prev     json.RawMessage
+	Value    json.RawMessage
+}
+
+type history struct {
+	size func() int
+
+	mu   sync.Mutex
+	next int
+	revs []Revision
+}
+
+func (h *history) add(rev Revision) Revision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next++
+	rev.ID = h.next
+	h.revs = append(h.revs, rev)
+	if n := h.size(); n > 0 && len(h.revs) > n {
+		h.revs = append(h.revs[:0:0], h.revs[len(h.revs)-n:]...)
+	}
+	return rev
+}
+
+func (h *history) list(module string) []Revision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	revs := []Revision{}
+	for _, r := range h.revs {
+		if r.Module == module {
+			revs = append(revs, r)
+		}
+	}
+	return revs
+}
+
+func (h *history) get(module string, id int) (Revision, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.revs {
+		if r.Module == module && r.ID == id {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// redactRaw redacts the secret fields in data, which is the JSON of a value of the same type as v.
+func redactRaw(data json.RawMessage, v interface{}) (json.RawMessage, error) {
+	var x interface{}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	jsoncfg.RedactTree(x, v)
+	return snapshot(x)
+}
+
+// caller returns the remote address and the authenticated user of the request in ctx,
+// or the basic auth user if the backend does not authenticate.
+func caller(ctx context.Context) (addr, user string) {
+	r := context_value.ParseRequest(ctx)
+	if r == nil {
+		return "", ""
+	}
+	if id, ok := backend_module.RequestIdentity(r); ok {
+		return r.RemoteAddr, id.User
+	}
+	user, _, _ = r.BasicAuth()
+	return r.RemoteAddr, user
+}
@@ -0,0 +1,45 @@
+package dashboard_module
+
+import (
+	"reflect"
+	"testing"
+)
+
+func revisionIDs(revs []Revision) []int {
+	ids := []int{}
+	for _, r := range revs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestHistory(t *testing.T) {
+	h := history{size: func() int { return 3 }}
+	for _, module := range []string{"a", "b", "a", "a", "b"} {
+		h.add(Revision{Module: module})
+	}
+
+	tests := []struct {
+		module string
+		ids    []int
+	}{
+		{module: "a", ids: []int{3, 4}},
+		{module: "b", ids: []int{5}},
+		{module: "c", ids: []int{}},
+	}
+	for i, tt := range tests {
+		if got, want := revisionIDs(h.list(tt.module)), tt.ids; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: %s revisions: got(%v) != want(%v)", i, tt.module, got, want)
+		}
+	}
+
+	if _, ok := h.get("a", 1); ok {
+		t.Errorf("evicted revision 1 found")
+	}
+	if _, ok := h.get("b", 4); ok {
+		t.Errorf("revision 4 found in module b")
+	}
+	if r, ok := h.get("a", 4); !ok || r.ID != 4 {
+		t.Errorf("revision 4 not found: %v, %v", r, ok)
+	}
+}
@@ -1,33 +1,77 @@
 package dashboard_module
 
 import (
+	"errors"
+
 	"github.com/ironzhang/matrix/framework"
 	"github.com/ironzhang/matrix/framework/modules/backend-module"
 	"github.com/ironzhang/matrix/restful"
 	"github.com/ironzhang/matrix/tlog"
 )
 
-var Module = &M{}
+var Config = &C{
+	Persist:     false,
+	HistorySize: 100,
+}
+
+var Module = New(backend_module.Module)
 
 func init() {
-	framework.Register(Module, nil, nil)
+	framework.Register(Module, nil, Config)
+}
+
+type C struct {
+	Persist     bool `json:",writeable" usage:"修改配置后是否将修改的字段保存到最后一个配置文件"`
+	HistorySize int  `json:",writeable" usage:"保留的配置修改记录条数"`
+}
+
+// config returns the current config registered with the module, nil if the module is
+// registered without one.
+func config() *C {
+	c, _ := framework.Configs().GetSnapshot(Module.Name())
+	x, _ := c.(*C)
+	return x
 }
 
 type M struct {
+	backend *backend_module.M
+}
+
+// New returns a dashboard module serving on backend.
+func New(backend *backend_module.M) *M {
+	return &M{backend: backend}
 }
 
 func (m *M) Name() string {
 	return "dashboard-module"
 }
 
+func (m *M) Depends() []string {
+	return []string{"backend-module"}
+}
+
 func (m *M) Init() (err error) {
-	h := handlers{configs: framework.Configs()}
+	if config() == nil {
+		return errors.New("config not registered")
+	}
+	h := handlers{
+		configs: framework.Configs(),
+		flags:   framework.Flags(),
+		options: framework.ListOptions,
+		module:  framework.ModuleOptions,
+		runners: framework.Runners,
+		modules: framework.ModuleStatuses,
+		persist: func() bool { return config().Persist },
+		save:    framework.SaveAppConfig,
+		diff:    framework.DiffAppConfig,
+		history: &history{size: func() int { return config().HistorySize }},
+	}
 	mux := restful.NewServeMux(nil)
 	if err = h.Register(mux); err != nil {
 		return err
 	}
-	backend_module.Module.Handle("/dashboard/", mux)
-	backend_module.Module.Handle("/dashboard/log/level", tlog.Level())
+	m.backend.Handle("/dashboard/", mux)
+	m.backend.Handle("/dashboard/log/level", tlog.Level())
 	return nil
 }
 
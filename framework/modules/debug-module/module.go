@@ -8,26 +8,37 @@ import (
 	"github.com/ironzhang/matrix/framework/modules/backend-module"
 )
 
-var Module = &M{}
+var Module = New(backend_module.Module)
 
 func init() {
 	framework.Register(Module, nil, nil)
 }
 
 type M struct {
+	backend *backend_module.M
+}
+
+// New returns a debug module serving on backend.
+func New(backend *backend_module.M) *M {
+	return &M{backend: backend}
 }
 
 func (m *M) Name() string {
 	return "debug-module"
 }
 
+func (m *M) Depends() []string {
+	return []string{"backend-module"}
+}
+
 func (m *M) Init() error {
-	backend_module.Module.Handle("/debug/vars", expvar.Handler())
-	backend_module.Module.HandleFunc("/debug/pprof/", pprof.Index)
-	backend_module.Module.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	backend_module.Module.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	backend_module.Module.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	backend_module.Module.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	m.backend.Handle("/debug/vars", expvar.Handler())
+	m.backend.HandleFunc("/debug/pprof/", pprof.Index)
+	m.backend.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	m.backend.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	m.backend.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	m.backend.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	m.backend.Require("/debug/pprof/", backend_module.Operator)
 	return nil
 }
 
@@ -1,6 +1,8 @@
 package etcd_module
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,11 +23,21 @@ func init() {
 }
 
 type C struct {
-	Endpoints        []string
-	AutoSyncInterval jsoncfg.Duration
-	DialTimeout      jsoncfg.Duration
-	Username         string
-	Password         string
+	Endpoints        []string         `usage:"etcd服务地址列表"`
+	AutoSyncInterval jsoncfg.Duration `usage:"自动同步etcd成员列表的间隔, 0表示不同步"`
+	DialTimeout      jsoncfg.Duration `usage:"连接超时时间"`
+	Username         string           `usage:"用户名"`
+	Password         string           `json:",secret" usage:"密码"`
+}
+
+func (c *C) Validate() error {
+	if len(c.Endpoints) == 0 {
+		return errors.New("no endpoints")
+	}
+	if c.DialTimeout < 0 {
+		return fmt.Errorf("invalid dial timeout: %s", c.DialTimeout)
+	}
+	return nil
 }
 
 type M struct {
@@ -58,3 +70,10 @@ func (m *M) Fini() error {
 func (m *M) Client() *clientv3.Client {
 	return m.client
 }
+
+func (m *M) CheckHealth(ctx context.Context) error {
+	if _, err := m.client.Get(ctx, "health"); err != nil {
+		return fmt.Errorf("get: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package health_module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ironzhang/matrix/framework"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/jsoncfg"
+)
+
+var Config = &C{
+	Timeout: jsoncfg.Duration(3 * time.Second),
+}
+
+var Module = New(backend_module.Module)
+
+func init() {
+	framework.Register(Module, nil, Config)
+}
+
+type C struct {
+	Timeout jsoncfg.Duration `json:",writeable" usage:"健康检查超时时间"`
+}
+
+// config returns the current config registered with the module, nil if the module is
+// registered without one.
+func config() *C {
+	c, _ := framework.Configs().GetSnapshot(Module.Name())
+	x, _ := c.(*C)
+	return x
+}
+
+type Status struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Result struct {
+	Status  string            `json:"status"`
+	Modules map[string]Status `json:"modules"`
+}
+
+var (
+	errNotReady = errors.New("not ready")
+	errStopping = errors.New("stopping")
+	errNoConfig = errors.New("config not registered")
+)
+
+type M struct {
+	backend *backend_module.M
+	state   int32
+}
+
+// New returns a health module serving on backend.
+func New(backend *backend_module.M) *M {
+	return &M{backend: backend}
+}
+
+const (
+	starting int32 = iota
+	running
+	stopping
+)
+
+func (m *M) Name() string {
+	return "health-module"
+}
+
+func (m *M) Depends() []string {
+	return []string{"backend-module"}
+}
+
+func (m *M) Init() error {
+	if config() == nil {
+		return errNoConfig
+	}
+	m.backend.HandleFunc("/healthz", m.serveHealthz)
+	m.backend.HandleFunc("/readyz", m.serveReadyz)
+	m.backend.HandleFunc("/livez", m.serveLivez)
+	m.backend.Require("/healthz", backend_module.Anonymous)
+	m.backend.Require("/readyz", backend_module.Anonymous)
+	m.backend.Require("/livez", backend_module.Anonymous)
+	return nil
+}
+
+func (m *M) Fini() error {
+	return nil
+}
+
+func (m *M) Run(ctx context.Context) error {
+	atomic.StoreInt32(&m.state, running)
+	<-ctx.Done()
+	atomic.StoreInt32(&m.state, stopping)
+	return nil
+}
+
+func (m *M) CheckReady(ctx context.Context) error {
+	switch atomic.LoadInt32(&m.state) {
+	case starting:
+		return errNotReady
+	case stopping:
+		return errStopping
+	}
+	return nil
+}
+
+func (m *M) Healthz(ctx context.Context) Result {
+	return check(ctx, func(mod framework.Module) (func(context.Context) error, bool) {
+		c, ok := mod.(framework.HealthChecker)
+		if !ok {
+			return nil, false
+		}
+		return c.CheckHealth, true
+	})
+}
+
+func (m *M) Readyz(ctx context.Context) Result {
+	return check(ctx, func(mod framework.Module) (func(context.Context) error, bool) {
+		c, ok := mod.(framework.ReadyChecker)
+		if !ok {
+			return nil, false
+		}
+		return c.CheckReady, true
+	})
+}
+
+func (m *M) Livez(ctx context.Context) Result {
+	r := Result{Status: "ok", Modules: make(map[string]Status)}
+	if atomic.LoadInt32(&m.state) == stopping {
+		return r
+	}
+	for _, s := range framework.Runners() {
+		if s.Running || s.LastError == "" || s.Policy == framework.IgnoreFailure {
+			r.Modules[s.Module] = Status{Status: "ok"}
+			continue
+		}
+		r.Status = "fail"
+		r.Modules[s.Module] = Status{Status: "fail", Error: s.LastError}
+	}
+	return r
+}
+
+func check(ctx context.Context, checker func(framework.Module) (func(context.Context) error, bool)) Result {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config().Timeout))
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	pending := make(map[string]bool)
+	results := make(chan result, len(framework.Modules()))
+	for _, mod := range framework.Modules() {
+		fn, ok := checker(mod)
+		if !ok {
+			continue
+		}
+		pending[mod.Name()] = true
+		go func(name string, fn func(context.Context) error) {
+			results <- result{name: name, err: fn(ctx)}
+		}(mod.Name(), fn)
+	}
+
+	r := Result{Status: "ok", Modules: make(map[string]Status)}
+	set := func(name string, err error) {
+		delete(pending, name)
+		if err != nil {
+			r.Status = "fail"
+			r.Modules[name] = Status{Status: "fail", Error: err.Error()}
+			return
+		}
+		r.Modules[name] = Status{Status: "ok"}
+	}
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			set(res.name, res.err)
+		case <-ctx.Done():
+			for name := range pending {
+				set(name, ctx.Err())
+			}
+		}
+	}
+	return r
+}
+
+func (m *M) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, m.Healthz(r.Context()))
+}
+
+func (m *M) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, m.Readyz(r.Context()))
+}
+
+func (m *M) serveLivez(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, m.Livez(r.Context()))
+}
+
+func writeResult(w http.ResponseWriter, r Result) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(r)
+}
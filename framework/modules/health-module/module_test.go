@@ -0,0 +1,225 @@
+package health_module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/matrix/framework"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/jsoncfg"
+)
+
+type checker struct {
+	name     string
+	health   error
+	ready    error
+	blocking bool
+}
+
+func (c *checker) Name() string { return c.name }
+func (c *checker) Init() error  { return nil }
+func (c *checker) Fini() error  { return nil }
+
+func (c *checker) CheckHealth(ctx context.Context) error {
+	if c.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return c.health
+}
+
+func (c *checker) CheckReady(ctx context.Context) error {
+	return c.ready
+}
+
+type crasher struct {
+	checker
+}
+
+func (c *crasher) Run(ctx context.Context) error {
+	return errors.New("crashed")
+}
+
+// startApp starts an app with a backend module requiring a token, the health module and ms.
+func startApp(t *testing.T, ms ...framework.Module) (*framework.App, *backend_module.M, *M) {
+	app := framework.New(framework.AppOptions{})
+	bc := &backend_module.C{
+		Addr:       "127.0.0.1:0",
+		SocketMode: "0600",
+		Auth:       backend_module.AuthConfig{Tokens: []backend_module.Token{{Token: "t0ken", User: "u", Role: backend_module.Viewer}}},
+	}
+	b := backend_module.New(bc)
+	h := New(b)
+	if err := app.Register(b, nil, bc); err != nil {
+		t.Fatalf("register backend: %v", err)
+	}
+	if err := app.Register(h, nil, &C{Timeout: jsoncfg.Duration(100 * time.Millisecond)}); err != nil {
+		t.Fatalf("register health: %v", err)
+	}
+	for _, m := range ms {
+		if err := app.Register(m, nil, nil); err != nil {
+			t.Fatalf("register %s: %v", m.Name(), err)
+		}
+	}
+	app.SetRestartPolicy("crasher", framework.RestartPolicy{Policy: framework.RestartOnFailure, MinBackoff: time.Hour})
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; h.CheckReady(context.Background()) != nil || b.CheckHealth(context.Background()) != nil; i++ {
+		if i >= 100 {
+			t.Fatalf("app not running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return app, b, h
+}
+
+func serve(t *testing.T, handler http.HandlerFunc) (int, Result) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+	var r Result
+	if err := json.Unmarshal(w.Body.Bytes(), &r); err != nil {
+		t.Fatalf("unmarshal %s: %v", w.Body.String(), err)
+	}
+	return w.Code, r
+}
+
+func TestHandlers(t *testing.T) {
+	app, _, h := startApp(t,
+		&checker{name: "healthy"},
+		&checker{name: "failing", health: errors.New("disk full")},
+		&checker{name: "starting", ready: errNotReady},
+		&checker{name: "blocking", blocking: true},
+		&crasher{checker{name: "crasher"}},
+	)
+	defer app.Stop()
+
+	ok, fail := Status{Status: "ok"}, func(err string) Status { return Status{Status: "fail", Error: err} }
+	tests := []struct {
+		handler http.HandlerFunc
+		code    int
+		status  string
+		modules map[string]Status
+	}{
+		{
+			handler: h.serveHealthz,
+			code:    http.StatusServiceUnavailable,
+			status:  "fail",
+			modules: map[string]Status{
+				"backend-module": ok,
+				"healthy":        ok,
+				"starting":       ok,
+				"crasher":        ok,
+				"failing":        fail("disk full"),
+				"blocking":       fail(context.DeadlineExceeded.Error()),
+			},
+		},
+		{
+			handler: h.serveReadyz,
+			code:    http.StatusServiceUnavailable,
+			status:  "fail",
+			modules: map[string]Status{
+				"backend-module": ok,
+				"health-module":  ok,
+				"healthy":        ok,
+				"failing":        ok,
+				"blocking":       ok,
+				"crasher":        ok,
+				"starting":       fail("not ready"),
+			},
+		},
+		{
+			// the runners restarting after failures are alive
+			handler: h.serveLivez,
+			code:    http.StatusOK,
+			status:  "ok",
+			modules: map[string]Status{
+				"backend-module": ok,
+				"health-module":  ok,
+				"crasher":        ok,
+			},
+		},
+	}
+	for i, tt := range tests {
+		code, r := serve(t, tt.handler)
+		if code != tt.code || r.Status != tt.status {
+			t.Errorf("tests[%d]: code(%d), status(%s)", i, code, r.Status)
+		}
+		if got, want := r.Modules, tt.modules; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: modules: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestHandlersOK(t *testing.T) {
+	app, _, h := startApp(t, &checker{name: "healthy"})
+	for i, handler := range []http.HandlerFunc{h.serveHealthz, h.serveReadyz, h.serveLivez} {
+		if code, r := serve(t, handler); code != http.StatusOK || r.Status != "ok" {
+			t.Errorf("tests[%d]: code(%d), result(%+v)", i, code, r)
+		}
+	}
+
+	// the health module is not ready while stopping
+	app.Stop()
+	if err := h.CheckReady(context.Background()); err != errStopping {
+		t.Errorf("ready after stop: got(%v) != want(%v)", err, errStopping)
+	}
+}
+
+func TestAnonymousRoutes(t *testing.T) {
+	app, b, _ := startApp(t)
+	defer app.Stop()
+
+	tests := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{path: "/healthz", code: http.StatusOK},
+		{path: "/readyz", code: http.StatusOK},
+		{path: "/livez", code: http.StatusOK},
+		{path: "/other", code: http.StatusUnauthorized},
+		{path: "/other", token: "t0ken", code: http.StatusNotFound},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest("GET", "http://"+b.Addr().String()+tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("tests[%d]: get %s: %v", i, tt.path, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("tests[%d]: %s: code: got(%d) != want(%d)", i, tt.path, resp.StatusCode, tt.code)
+		}
+	}
+}
+
+func TestModuleNoConfig(t *testing.T) {
+	app := framework.New(framework.AppOptions{})
+	bc := &backend_module.C{Addr: "127.0.0.1:0", SocketMode: "0600"}
+	b := backend_module.New(bc)
+	if err := app.Register(b, nil, bc); err != nil {
+		t.Fatalf("register backend: %v", err)
+	}
+	if err := app.Register(New(b), nil, nil); err != nil {
+		t.Fatalf("register health: %v", err)
+	}
+	err := app.Start(context.Background())
+	if err == nil {
+		app.Stop()
+	}
+	if err == nil || !strings.Contains(err.Error(), errNoConfig.Error()) {
+		t.Errorf("start: got(%v) != want(%v)", err, errNoConfig)
+	}
+}
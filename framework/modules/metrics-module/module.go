@@ -0,0 +1,76 @@
+package metrics_module
+
+import (
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/ironzhang/matrix/framework"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/metrics"
+)
+
+var Config = &C{
+	Path: "/metrics",
+}
+
+var Module = New(backend_module.Module)
+
+func init() {
+	framework.Register(Module, nil, Config)
+
+	start := float64(time.Now().Unix())
+	metrics.NewGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+		return start
+	})
+	metrics.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	metrics.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", func() float64 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return float64(ms.HeapAlloc)
+	})
+}
+
+type C struct {
+	Path string `json:",readonly" usage:"Prometheus指标路径"`
+}
+
+// config returns the current config registered with the module, nil if the module is
+// registered without one.
+func config() *C {
+	c, _ := framework.Configs().GetSnapshot(Module.Name())
+	x, _ := c.(*C)
+	return x
+}
+
+type M struct {
+	backend *backend_module.M
+}
+
+// New returns a metrics module serving on backend.
+func New(backend *backend_module.M) *M {
+	return &M{backend: backend}
+}
+
+func (m *M) Name() string {
+	return "metrics-module"
+}
+
+func (m *M) Depends() []string {
+	return []string{"backend-module"}
+}
+
+func (m *M) Init() error {
+	c := config()
+	if c == nil {
+		return errors.New("config not registered")
+	}
+	m.backend.Handle(c.Path, metrics.Handler())
+	return nil
+}
+
+func (m *M) Fini() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package metrics_module
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ironzhang/matrix/framework"
+	"github.com/ironzhang/matrix/framework/modules/backend-module"
+	"github.com/ironzhang/matrix/metrics"
+)
+
+// requests is registered once on the default registry, so the test can be run with -count.
+var requests = metrics.NewCounter("test_requests_total", "The requests of the test.", "code")
+
+func TestScrape(t *testing.T) {
+	app := framework.New(framework.AppOptions{})
+	bc := &backend_module.C{Addr: "127.0.0.1:0", SocketMode: "0600"}
+	b := backend_module.New(bc)
+	if err := app.Register(b, nil, bc); err != nil {
+		t.Fatalf("register backend: %v", err)
+	}
+	if err := app.Register(New(b), nil, &C{Path: "/prometheus"}); err != nil {
+		t.Fatalf("register metrics: %v", err)
+	}
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer app.Stop()
+
+	requests.Inc("200")
+
+	resp, err := http.Get("http://" + b.Addr().String() + "/prometheus")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code: got(%d) != want(%d)", resp.StatusCode, http.StatusOK)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; got != want {
+		t.Errorf("content type: got(%s) != want(%s)", got, want)
+	}
+
+	tests := []struct {
+		name string
+		typ  string
+		help string
+		line string
+	}{
+		{name: "process_start_time_seconds", typ: "gauge", help: "Start time of the process since unix epoch in seconds.", line: `process_start_time_seconds [0-9.e+]+`},
+		{name: "go_goroutines", typ: "gauge", help: "Number of goroutines that currently exist.", line: `go_goroutines [1-9][0-9]*`},
+		{name: "go_memstats_heap_alloc_bytes", typ: "gauge", help: "Number of heap bytes allocated and still in use.", line: `go_memstats_heap_alloc_bytes [0-9.e+]+`},
+		{name: "test_requests_total", typ: "counter", help: "The requests of the test.", line: `test_requests_total\{code="200"\} [1-9][0-9]*`},
+	}
+	s := string(body)
+	for i, tt := range tests {
+		if !strings.Contains(s, "# HELP "+tt.name+" "+tt.help+"\n") {
+			t.Errorf("tests[%d]: %s: no help line", i, tt.name)
+		}
+		if !strings.Contains(s, "# TYPE "+tt.name+" "+tt.typ+"\n") {
+			t.Errorf("tests[%d]: %s: no type line", i, tt.name)
+		}
+		if !regexp.MustCompile(`(?m)^` + tt.line + `$`).MatchString(s) {
+			t.Errorf("tests[%d]: %s: no sample matches %s", i, tt.name, tt.line)
+		}
+	}
+	if t.Failed() {
+		t.Logf("exposition:\n%s", s)
+	}
+}
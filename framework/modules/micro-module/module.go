@@ -1,6 +1,7 @@
 package micro_module
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ironzhang/matrix/framework"
@@ -23,9 +24,19 @@ func init() {
 }
 
 type C struct {
-	Namespace string
-	Timeout   jsoncfg.Duration
-	TTL       int64
+	Namespace string           `usage:"服务注册及发现的命名空间"`
+	Timeout   jsoncfg.Duration `usage:"注册及发现的超时时间"`
+	TTL       int64            `usage:"服务注册的TTL(秒)"`
+}
+
+func (c *C) Validate() error {
+	if c.TTL <= 0 {
+		return fmt.Errorf("invalid ttl: %d", c.TTL)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", c.Timeout)
+	}
+	return nil
 }
 
 type M struct {
@@ -37,6 +48,10 @@ func (m *M) Name() string {
 	return "micro-module"
 }
 
+func (m *M) Depends() []string {
+	return []string{"etcd-module"}
+}
+
 func (m *M) Init() error {
 	c := etcd_module.Module.Client()
 	m.r = registry.New(c, registry.Options{
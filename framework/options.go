@@ -0,0 +1,71 @@
+package framework
+
+import (
+	"flag"
+	"strings"
+)
+
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+type Option struct {
+	Name      string
+	Value     string
+	Default   string
+	Source    string
+	Usage     string
+	Writeable bool
+}
+
+func (f *framework) moduleOptions(module string) ([]Option, bool) {
+	fls, ok := f.optionFlags[module]
+	if !ok {
+		return nil, false
+	}
+	v, _ := f.flags.GetValue(module)
+
+	opts := make([]Option, 0, len(fls))
+	for _, fl := range fls {
+		source := SourceDefault
+		if s, ok := f.optionSources[fl.Name]; ok {
+			source = s
+		}
+		path := fl.Name
+		if module != "" {
+			path = strings.TrimPrefix(fl.Name, module+".")
+		}
+		opts = append(opts, Option{
+			Name:      fl.Name,
+			Value:     fl.Value.String(),
+			Default:   fl.DefValue,
+			Source:    source,
+			Usage:     fl.Usage,
+			Writeable: v != nil && v.Writeable(path),
+		})
+	}
+	return opts, true
+}
+
+func (f *framework) listOptions() map[string][]Option {
+	m := make(map[string][]Option, len(f.optionFlags))
+	for module := range f.optionFlags {
+		m[module], _ = f.moduleOptions(module)
+	}
+	return m
+}
+
+// recordFlags records the flags which are newly added to the command line by module.
+func (f *framework) recordFlags(module string, seen map[string]bool) {
+	if f.optionFlags == nil {
+		f.optionFlags = make(map[string][]*flag.Flag)
+	}
+	f.commandLine.VisitAll(func(fl *flag.Flag) {
+		if !seen[fl.Name] {
+			seen[fl.Name] = true
+			f.optionFlags[module] = append(f.optionFlags[module], fl)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package framework
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+type testOptions struct {
+	Addr    string `usage:"listen address"`
+	Verbose int    `json:",writeable" usage:"verbose level"`
+}
+
+func TestListOptions(t *testing.T) {
+	var f framework
+	f.commandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	f.commandLine.String("other", "", "not an option of modules")
+	f.flags.Register("a", &testOptions{Addr: ":6060"})
+	if err := f.parseCommandLine([]string{"-a.Verbose", "2", "-other", "x"}); err != nil {
+		t.Fatalf("parse command line: %v", err)
+	}
+
+	opts, ok := f.moduleOptions("a")
+	if !ok {
+		t.Fatalf("module options not found")
+	}
+	want := []Option{
+		{Name: "a.Addr", Value: ":6060", Default: ":6060", Source: SourceDefault, Usage: "listen address", Writeable: false},
+		{Name: "a.Verbose", Value: "2", Default: "0", Source: SourceFlag, Usage: "verbose level", Writeable: true},
+	}
+	if got := opts; !reflect.DeepEqual(got, want) {
+		t.Errorf("module options: got(%v) != want(%v)", got, want)
+	}
+
+	all := f.listOptions()
+	if _, ok := all[""]; !ok {
+		t.Errorf("framework options not found")
+	}
+	if got, want := all["a"], want; !reflect.DeepEqual(got, want) {
+		t.Errorf("list options: got(%v) != want(%v)", got, want)
+	}
+	for module, opts := range all {
+		for _, o := range opts {
+			if o.Name == "other" {
+				t.Errorf("flag other listed in module %q", module)
+			}
+		}
+	}
+
+	if _, ok = f.moduleOptions("b"); ok {
+		t.Errorf("module b options found")
+	}
+}
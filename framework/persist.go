@@ -0,0 +1,276 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/ironzhang/matrix/jsoncfg"
+	"github.com/ironzhang/matrix/tlog"
+)
+
+var errNoConfigFile = errors.New("no config file")
+
+type ConfigDiff struct {
+	Path    string
+	Running interface{}
+	File    interface{}
+}
+
+// saveAppConfig writes the fields of the module config changed since prev, the snapshot
+// before the change, to the last config file, which overrides the others. The fields
+// equal to the merged config files and the fields set by env are not written. Only the
+// changed keys are patched, the rest of the file, such as the comments, the includes
+// and the secret references, is kept, so TOML files, which can not be patched, are not
+// supported. The secret fields are never written, they can only be set by references.
+func (f *framework) saveAppConfig(module string, prev interface{}) error {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+
+	files := configFiles(f.options.ConfigFile)
+	if len(files) == 0 {
+		return errNoConfigFile
+	}
+	file := files[len(files)-1]
+	format := jsoncfg.FormatOf(file)
+	if format == jsoncfg.TOML {
+		return fmt.Errorf("%s: saving to toml files is not supported", file)
+	}
+	v, ok := f.configs.GetValue(module)
+	if !ok {
+		return fmt.Errorf("module config not found: %s", module)
+	}
+
+	changes, err := changedFields(prev, v.Snapshot())
+	if err != nil {
+		return err
+	}
+	sections, err := loadSections(f.readFile, f.getenv, f.options.ConfigFile)
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]interface{})
+	if data, ok := sections[module]; ok {
+		var x interface{}
+		if err = json.Unmarshal(data, &x); err != nil {
+			return err
+		}
+		flattenValue(merged, "", x)
+	}
+
+	var secrets []string
+	patch := make(map[string]interface{})
+	redacted := redactedFields(changes, v.Snapshot())
+	for path, value := range changes {
+		if _, ok := f.envConfigs[module][path]; ok {
+			continue
+		}
+		if w, ok := merged[path]; ok && reflect.DeepEqual(w, value) {
+			continue
+		}
+		if redacted[path] {
+			secrets = append(secrets, path)
+			continue
+		}
+		patch[joinPath(module, path)] = value
+	}
+	if len(secrets) > 0 {
+		sort.Strings(secrets)
+		tlog.Std().Sugar().Warnw("secret fields not saved, set them by references in the config files", "module", module, "fields", secrets)
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+
+	data, err := f.readFile.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if data, err = jsoncfg.Patch(format, data, patch); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if err = writeFileAtomic(file, data); err != nil {
+		return err
+	}
+	if sections, err = loadSections(f.readFile, f.getenv, f.options.ConfigFile); err != nil {
+		return err
+	}
+	f.sections = sections
+	return nil
+}
+
+// changedFields returns the leaf values of cur which differ from prev, keyed by the dotted paths.
+func changedFields(prev, cur interface{}) (map[string]interface{}, error) {
+	x, err := flatten(prev)
+	if err != nil {
+		return nil, err
+	}
+	y, err := flatten(cur)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range y {
+		if w, ok := x[k]; ok && reflect.DeepEqual(v, w) {
+			delete(y, k)
+		}
+	}
+	return y, nil
+}
+
+// redactedFields returns the paths of the changes which contain the values of secret fields.
+func redactedFields(changes map[string]interface{}, v interface{}) map[string]bool {
+	x := make(map[string]interface{})
+	for path, value := range changes {
+		setPath(x, path, value)
+	}
+	y, err := normalize(x)
+	if err != nil {
+		return nil
+	}
+	jsoncfg.RedactTree(y, v)
+	leaves := make(map[string]interface{})
+	flattenValue(leaves, "", y)
+
+	redacted := make(map[string]bool)
+	for path, value := range changes {
+		if !reflect.DeepEqual(leaves[path], value) {
+			redacted[path] = true
+		}
+	}
+	return redacted
+}
+
+// writeFileAtomic writes data to a temp file and renames it to filename,
+// the previous content of filename is kept in filename.bak.
+func writeFileAtomic(filename string, data []byte) (err error) {
+	perm := os.FileMode(0666)
+	if fi, err := os.Stat(filename); err == nil {
+		perm = fi.Mode().Perm()
+		old, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filename+".bak", old, perm); err != nil {
+			return fmt.Errorf("backup: %v", err)
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// diffAppConfig compares the running configs with the config files, the secret fields are redacted.
+func (f *framework) diffAppConfig() ([]ConfigDiff, error) {
+	file := f.options.ConfigFile
+	if file == "" {
+		return nil, errNoConfigFile
+	}
+	sections, err := loadSections(f.readFile, f.getenv, file)
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]interface{})
+	ondisk := make(map[string]interface{})
+	for k, v := range f.configs.Snapshots() {
+		if running[k], err = jsoncfg.Redact(v); err != nil {
+			return nil, err
+		}
+		if data, ok := sections[k]; ok {
+			var x interface{}
+			if err = json.Unmarshal(data, &x); err != nil {
+				return nil, err
+			}
+			jsoncfg.RedactTree(x, v)
+			ondisk[k] = x
+		}
+	}
+
+	x, err := flatten(running)
+	if err != nil {
+		return nil, err
+	}
+	y, err := flatten(ondisk)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []ConfigDiff
+	for k, v := range x {
+		if w, ok := y[k]; !ok || !reflect.DeepEqual(v, w) {
+			diffs = append(diffs, ConfigDiff{Path: k, Running: v, File: w})
+		}
+	}
+	for k, w := range y {
+		if _, ok := x[k]; !ok {
+			diffs = append(diffs, ConfigDiff{Path: k, File: w})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// flatten converts v to a map whose keys are the dotted paths of the leaf values.
+func flatten(v interface{}) (map[string]interface{}, error) {
+	x, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	flattenValue(m, "", x)
+	return m, nil
+}
+
+// normalize converts v to its JSON representation.
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var x interface{}
+	if err = json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func flattenValue(m map[string]interface{}, path string, v interface{}) {
+	if x, ok := v.(map[string]interface{}); ok && len(x) > 0 {
+		for k, e := range x {
+			if path == "" {
+				flattenValue(m, k, e)
+			} else {
+				flattenValue(m, path+"."+k, e)
+			}
+		}
+		return
+	}
+	m[path] = v
+}
@@ -0,0 +1,266 @@
+package framework
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAppConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "base.json")
+	file := filepath.Join(dir, "cfg.json")
+	old := `{"include": "base.json", "x": {"Name": "x"}}`
+	if err = ioutil.WriteFile(base, []byte(`{"a": {"Addr": ":6060", "Verbose": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(file, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var f framework
+	f.options.ConfigFile = file
+	f.configs.Register("a", &testConfig{Addr: ":6060"})
+	f.configs.Register("b", &testConfig{Addr: ":7070", Verbose: 3})
+	if f.sections, err = loadAppConfig(&f.configs, nil, nil, file); err != nil {
+		t.Fatalf("load app config: %v", err)
+	}
+	f.envConfigs = map[string]map[string]interface{}{"b": {"Verbose": 3.0}}
+	f.configs.Seal()
+
+	store := func(module string, a map[string]interface{}) {
+		v, _ := f.configs.GetValue(module)
+		prev := v.Snapshot()
+		if err := v.Store(a); err != nil {
+			t.Fatalf("store %s: %v", module, err)
+		}
+		if err := f.saveAppConfig(module, prev); err != nil {
+			t.Fatalf("save app config: %v", err)
+		}
+	}
+	read := func(file string) map[string]interface{} {
+		var m map[string]interface{}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	// the env fields and the unchanged modules are not written
+	store("a", map[string]interface{}{"Verbose": 2})
+	store("b", map[string]interface{}{"Verbose": 4})
+	backup, err := ioutil.ReadFile(file + ".bak")
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	if got, want := string(backup), old; got != want {
+		t.Errorf("backup: got(%s) != want(%s)", got, want)
+	}
+	want := map[string]interface{}{
+		"include": "base.json",
+		"a":       map[string]interface{}{"Verbose": 2.0},
+		"x":       map[string]interface{}{"Name": "x"},
+	}
+	if got := read(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("config: got(%v) != want(%v)", got, want)
+	}
+
+	// the change back to the included value overrides the saved one
+	store("a", map[string]interface{}{"Verbose": 1})
+	want["a"] = map[string]interface{}{"Verbose": 1.0}
+	if got := read(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("config: got(%v) != want(%v)", got, want)
+	}
+	if got, want := read(base), map[string]interface{}{"a": map[string]interface{}{"Addr": ":6060", "Verbose": 1.0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("base config: got(%v) != want(%v)", got, want)
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("perm: got(%v) != want(%v)", got, want)
+	}
+}
+
+func TestSaveAppConfigComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file string
+		data string
+		want string
+	}{
+		{
+			file: "cfg.jsonc",
+			data: "{\n\t// module a\n\t\"a\": {\n\t\t\"Verbose\": 1, // level\n\t\t\"Addr\": \":6061\"\n\t}\n}\n",
+			want: "{\n\t// module a\n\t\"a\": {\n\t\t\"Verbose\": 2, // level\n\t\t\"Addr\": \":6061\"\n\t}\n}\n",
+		},
+		{
+			file: "cfg.yaml",
+			data: "# module a\na:\n  Verbose: 1 # level\n  Addr: \":6061\"\n",
+			want: "# module a\na:\n  Verbose: 2 # level\n  Addr: \":6061\"\n",
+		},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(dir, tt.file)
+		if err = ioutil.WriteFile(file, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var f framework
+		f.options.ConfigFile = file
+		f.configs.Register("a", &testConfig{})
+		if f.sections, err = loadAppConfig(&f.configs, nil, nil, file); err != nil {
+			t.Fatalf("tests[%d]: load app config: %v", i, err)
+		}
+		f.configs.Seal()
+		v, _ := f.configs.GetValue("a")
+		prev := v.Snapshot()
+		if err = v.Store(map[string]interface{}{"Verbose": 2}); err != nil {
+			t.Fatalf("tests[%d]: store: %v", i, err)
+		}
+		if err = f.saveAppConfig("a", prev); err != nil {
+			t.Fatalf("tests[%d]: save app config: %v", i, err)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != tt.want {
+			t.Errorf("tests[%d]: got:\n%s\nwant:\n%s", i, got, tt.want)
+		}
+	}
+}
+
+func TestDiffAppConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"a": {"Addr": ":6060", "Verbose": 1}, "x": {"Name": "x"}}`)
+	file.Close()
+
+	var f framework
+	f.options.ConfigFile = file.Name()
+	f.configs.Register("a", &testConfig{Addr: ":6060", Verbose: 2})
+	f.configs.Register("b", &testConfig{Addr: ":7070"})
+
+	diffs, err := f.diffAppConfig()
+	if err != nil {
+		t.Fatalf("diff app config: %v", err)
+	}
+	want := []ConfigDiff{
+		{Path: "a.Verbose", Running: 2.0, File: 1.0},
+		{Path: "b.Addr", Running: ":7070"},
+		{Path: "b.Verbose", Running: 0.0},
+	}
+	if got := diffs; !reflect.DeepEqual(got, want) {
+		t.Errorf("diffs: got(%v) != want(%v)", got, want)
+	}
+}
+
+type testSecretConfig struct {
+	User     string `json:",writeable"`
+	Password string `json:",secret"`
+}
+
+func TestSecretRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	file := filepath.Join(dir, "cfg.json")
+	old := `{"a": {"User": "${env:FRAMEWORK_TEST_USER}", "Password": "${file:` + secret + `}"}}`
+	if err = ioutil.WriteFile(file, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refs are resolved with the getenv and read funcs of the framework
+	var f framework
+	f.options.ConfigFile = file
+	f.getenv = testEnv(map[string]string{"FRAMEWORK_TEST_USER": "root"})
+	f.readFile = func(filename string) ([]byte, error) {
+		if filename == secret {
+			return []byte("s3cret\n"), nil
+		}
+		return ioutil.ReadFile(filename)
+	}
+	c := &testSecretConfig{}
+	f.configs.Register("a", c)
+	if f.sections, err = loadAppConfig(&f.configs, f.readFile, f.getenv, file); err != nil {
+		t.Fatalf("load app config: %v", err)
+	}
+	if got, want := *c, (testSecretConfig{User: "root", Password: "s3cret"}); got != want {
+		t.Errorf("config: got(%v) != want(%v)", got, want)
+	}
+
+	diffs, err := f.diffAppConfig()
+	if err != nil {
+		t.Fatalf("diff app config: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("diffs: %v", diffs)
+	}
+
+	// the secret set in plain text is not written
+	v, _ := f.configs.GetValue("a")
+	prev := v.Snapshot()
+	if err = v.Store(map[string]interface{}{"User": "admin", "Password": "plain"}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	diffs, err = f.diffAppConfig()
+	if err != nil {
+		t.Fatalf("diff app config: %v", err)
+	}
+	if got, want := diffs, []ConfigDiff{{Path: "a.User", Running: "admin", File: "root"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diffs: got(%v) != want(%v)", got, want)
+	}
+
+	var reads []string
+	read := f.readFile
+	f.readFile = func(filename string) ([]byte, error) {
+		reads = append(reads, filename)
+		return read(filename)
+	}
+	if err = f.saveAppConfig("a", prev); err != nil {
+		t.Fatalf("save app config: %v", err)
+	}
+	if len(reads) == 0 {
+		t.Errorf("config files not read by the read func")
+	}
+	var got map[string]interface{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": map[string]interface{}{"User": "admin", "Password": "${file:" + secret + "}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config: got(%v) != want(%v)", got, want)
+	}
+}
@@ -1,16 +1,27 @@
 package flags
 
 import (
+	"encoding"
 	"flag"
 	"fmt"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/ironzhang/matrix/errs"
 	"github.com/ironzhang/matrix/framework/pkg/tags"
 )
 
 func Setup(f *flag.FlagSet, value interface{}, name, usage string) (err error) {
+	return setup(flags{FlagSet: f}, value, name, usage)
+}
+
+// SetupLenient is like Setup, but skips the fields of unsupported kinds.
+func SetupLenient(f *flag.FlagSet, value interface{}, name, usage string) (err error) {
+	return setup(flags{FlagSet: f, lenient: true}, value, name, usage)
+}
+
+func setup(f flags, value interface{}, name, usage string) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -24,26 +35,21 @@ func Setup(f *flag.FlagSet, value interface{}, name, usage string) (err error) {
 		}
 	}()
 
-	flags{f}.SetupValue(name, usage, reflect.ValueOf(value).Elem())
+	f.SetupValue(name, usage, reflect.ValueOf(value).Elem())
 	return
 }
 
 type flags struct {
 	*flag.FlagSet
+	lenient bool
 }
 
 func (f flags) SetupValue(name, usage string, v reflect.Value) {
+	if fv, ok := scalarValue(v); ok {
+		f.Var(fv, name, usage)
+		return
+	}
 	switch k := v.Kind(); k {
-	case reflect.Bool:
-		f.Var(newBoolValue(v), name, usage)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		f.Var(newIntValue(v), name, usage)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		f.Var(newUintValue(v), name, usage)
-	case reflect.Float32, reflect.Float64:
-		f.Var(newFloatValue(v), name, usage)
-	case reflect.String:
-		f.Var(newStringValue(v), name, usage)
 	case reflect.Struct:
 		if name != "" {
 			name = name + "."
@@ -52,9 +58,74 @@ func (f flags) SetupValue(name, usage string, v reflect.Value) {
 			usage = usage + ": "
 		}
 		f.SetupStruct(name, usage, v)
-	default:
-		panic(errs.ErrorAt("flags.SetupValue", fmt.Errorf("unsupport %s kind", k)))
+		return
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			f.SetupValue(name, usage, v.Elem())
+			return
+		}
+	case reflect.Slice:
+		if supported(v.Type().Elem()) {
+			f.Var(newSliceValue(v), name, usage)
+			return
+		}
+	case reflect.Map:
+		if supported(v.Type().Key()) && supported(v.Type().Elem()) {
+			f.Var(newMapValue(v), name, usage)
+			return
+		}
 	}
+	if f.lenient {
+		return
+	}
+	panic(errs.ErrorAt("flags.SetupValue", fmt.Errorf("unsupport %s kind", v.Kind())))
+}
+
+var (
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// scalarValue returns the flag.Value which sets v from a single string. flag.Value and
+// encoding.TextUnmarshaler implementations are preferred to the kind of v.
+func scalarValue(v reflect.Value) (flag.Value, bool) {
+	if v.CanAddr() {
+		if pt := v.Addr().Type(); pt.Implements(flagValueType) {
+			return v.Addr().Interface().(flag.Value), true
+		} else if pt.Implements(textUnmarshalerType) {
+			return newTextValue(v), true
+		}
+	}
+	if v.Type() == durationType {
+		return newDurationValue(v), true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return newBoolValue(v), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newIntValue(v), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return newUintValue(v), true
+	case reflect.Float32, reflect.Float64:
+		return newFloatValue(v), true
+	case reflect.String:
+		return newStringValue(v), true
+	case reflect.Ptr:
+		if supported(v.Type().Elem()) {
+			return newPtrValue(v), true
+		}
+	}
+	return nil, false
+}
+
+// supported reports whether the values of t can be set from a single string.
+func supported(t reflect.Type) bool {
+	_, ok := scalarValue(reflect.New(t).Elem())
+	return ok
 }
 
 func (f flags) SetupStruct(prefix, usage string, v reflect.Value) {
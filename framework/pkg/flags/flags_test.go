@@ -2,8 +2,13 @@ package flags
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/ironzhang/matrix/jsoncfg"
 )
 
 func ExampleUsage1() {
@@ -223,3 +228,211 @@ func TestSetup2(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSetupLenient(t *testing.T) {
+	type V struct {
+		S string                 `json:"s"`
+		L []string               `json:"l"`
+		C chan int               `json:"c"`
+		F func()                 `json:"f"`
+		X []struct{ A int }      `json:"x"`
+		M map[string]interface{} `json:"m"`
+	}
+
+	var v V
+	f := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := Setup(f, &v, "", ""); err == nil {
+		t.Errorf("setup expect error but not")
+	}
+
+	f = flag.NewFlagSet("", flag.ContinueOnError)
+	if err := SetupLenient(f, &v, "", ""); err != nil {
+		t.Fatalf("setup lenient: %v", err)
+	}
+	var names []string
+	f.VisitAll(func(fl *flag.Flag) { names = append(names, fl.Name) })
+	if got, want := names, []string{"l", "s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flags: got(%v) != want(%v)", got, want)
+	}
+}
+
+type levelValue int
+
+func (l *levelValue) Set(s string) error {
+	switch s {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level: %s", s)
+	}
+	return nil
+}
+
+func (l *levelValue) String() string {
+	return [...]string{"debug", "info"}[*l]
+}
+
+type compositeValues struct {
+	Addrs    []string          `json:"addrs"`
+	Ports    []int             `json:"ports"`
+	Labels   map[string]string `json:"labels"`
+	Weights  map[string]int    `json:"weights"`
+	Timeout  time.Duration     `json:"timeout"`
+	Interval jsoncfg.Duration  `json:"interval"`
+	Time     time.Time         `json:"time"`
+	Limit    *int              `json:"limit"`
+	Level    levelValue        `json:"level"`
+	Sub      *compositeSub     `json:"sub"`
+}
+
+type compositeSub struct {
+	Name string `json:"name"`
+}
+
+func TestSetupComposite(t *testing.T) {
+	limit := 10
+	tests := []struct {
+		args []string
+		want compositeValues
+	}{
+		{
+			args: []string{},
+			want: compositeValues{
+				Addrs:   []string{":80"},
+				Ports:   []int{80},
+				Labels:  map[string]string{"a": "1"},
+				Timeout: time.Second,
+				Sub:     &compositeSub{},
+			},
+		},
+		{
+			args: []string{
+				"-addrs", ":1,:2", "-addrs", ":3",
+				"-ports", "1", "-ports", "2",
+				"-labels", "b=2,c=3", "-labels", "d=4",
+				"-weights", "x=1",
+				"-timeout", "1m",
+				"-interval", "2s",
+				"-time", "2020-01-02T03:04:05Z",
+				"-limit", "10",
+				"-level", "info",
+				"-sub.name", "s",
+			},
+			want: compositeValues{
+				Addrs:    []string{":1", ":2", ":3"},
+				Ports:    []int{1, 2},
+				Labels:   map[string]string{"b": "2", "c": "3", "d": "4"},
+				Weights:  map[string]int{"x": 1},
+				Timeout:  time.Minute,
+				Interval: jsoncfg.Duration(2 * time.Second),
+				Time:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				Limit:    &limit,
+				Level:    1,
+				Sub:      &compositeSub{Name: "s"},
+			},
+		},
+		{
+			args: []string{"-addrs", "", "-ports", "", "-labels", ""},
+			want: compositeValues{
+				Addrs:   []string{},
+				Ports:   []int{},
+				Labels:  map[string]string{},
+				Timeout: time.Second,
+				Sub:     &compositeSub{},
+			},
+		},
+		{
+			args: []string{"-addrs", "", "-addrs", ":1"},
+			want: compositeValues{
+				Addrs:   []string{":1"},
+				Ports:   []int{80},
+				Labels:  map[string]string{"a": "1"},
+				Timeout: time.Second,
+				Sub:     &compositeSub{},
+			},
+		},
+	}
+	for i, tt := range tests {
+		v := compositeValues{
+			Addrs:   []string{":80"},
+			Ports:   []int{80},
+			Labels:  map[string]string{"a": "1"},
+			Timeout: time.Second,
+		}
+		f := flag.NewFlagSet("", flag.ContinueOnError)
+		if err := Setup(f, &v, "", ""); err != nil {
+			t.Fatalf("tests[%d]: setup: %v", i, err)
+		}
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("tests[%d]: parse: %v", i, err)
+		}
+		if got, want := v, tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: got(%+v) != want(%+v)", i, got, want)
+		}
+	}
+}
+
+func TestSetError(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "timeout", value: "1x"},
+		{name: "interval", value: "1x"},
+		{name: "limit", value: "x"},
+		{name: "ports", value: "1,x"},
+		{name: "labels", value: "b=2,c"},
+		{name: "weights", value: "x=1,y=z"},
+	}
+	for i, tt := range tests {
+		v := compositeValues{Ports: []int{80}, Labels: map[string]string{"a": "1"}, Timeout: time.Second, Interval: jsoncfg.Duration(time.Minute)}
+		f := flag.NewFlagSet("", flag.ContinueOnError)
+		if err := Setup(f, &v, "", ""); err != nil {
+			t.Fatalf("tests[%d]: setup: %v", i, err)
+		}
+		if err := f.Set(tt.name, tt.value); err == nil {
+			t.Errorf("tests[%d]: set %s=%s expect error but not", i, tt.name, tt.value)
+		}
+		want := compositeValues{Ports: []int{80}, Labels: map[string]string{"a": "1"}, Timeout: time.Second, Interval: jsoncfg.Duration(time.Minute), Sub: &compositeSub{}}
+		if got := v; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: got(%+v) != want(%+v)", i, got, want)
+		}
+	}
+}
+
+func TestCompositeDefaults(t *testing.T) {
+	v := compositeValues{
+		Addrs:    []string{":1", ":2"},
+		Labels:   map[string]string{"b": "2", "a": "1"},
+		Timeout:  time.Second,
+		Interval: jsoncfg.Duration(time.Minute),
+	}
+	f := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := Setup(f, &v, "", ""); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "addrs", value: ":1,:2"},
+		{name: "ports", value: ""},
+		{name: "labels", value: "a=1,b=2"},
+		{name: "timeout", value: "1s"},
+		{name: "interval", value: "1m0s"},
+		{name: "limit", value: ""},
+		{name: "level", value: "debug"},
+	}
+	for i, tt := range tests {
+		fl := f.Lookup(tt.name)
+		if fl == nil {
+			t.Errorf("tests[%d]: flag %s not found", i, tt.name)
+			continue
+		}
+		if got, want := fl.DefValue, tt.value; got != want {
+			t.Errorf("tests[%d]: %s: got(%q) != want(%q)", i, tt.name, got, want)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package flags
 
 import (
+	"encoding"
+	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type boolValue reflect.Value
@@ -13,8 +18,11 @@ func newBoolValue(v reflect.Value) boolValue {
 
 func (v boolValue) Set(s string) error {
 	x, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
 	reflect.Value(v).SetBool(x)
-	return err
+	return nil
 }
 
 func (v boolValue) String() string {
@@ -33,8 +41,11 @@ func newIntValue(v reflect.Value) intValue {
 
 func (v intValue) Set(s string) error {
 	x, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return err
+	}
 	reflect.Value(v).SetInt(x)
-	return err
+	return nil
 }
 
 func (v intValue) String() string {
@@ -53,8 +64,11 @@ func newUintValue(v reflect.Value) uintValue {
 
 func (v uintValue) Set(s string) error {
 	x, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return err
+	}
 	reflect.Value(v).SetUint(x)
-	return err
+	return nil
 }
 
 func (v uintValue) String() string {
@@ -73,8 +87,11 @@ func newFloatValue(v reflect.Value) floatValue {
 
 func (v floatValue) Set(s string) error {
 	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
 	reflect.Value(v).SetFloat(x)
-	return err
+	return nil
 }
 
 func (v floatValue) String() string {
@@ -103,3 +120,189 @@ func (v stringValue) String() string {
 	}
 	return val.String()
 }
+
+type durationValue reflect.Value
+
+func newDurationValue(v reflect.Value) durationValue {
+	return durationValue(v)
+}
+
+func (v durationValue) Set(s string) error {
+	x, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	reflect.Value(v).SetInt(int64(x))
+	return nil
+}
+
+func (v durationValue) String() string {
+	val := reflect.Value(v)
+	if !val.IsValid() {
+		return "0s"
+	}
+	return time.Duration(val.Int()).String()
+}
+
+type textValue reflect.Value
+
+func newTextValue(v reflect.Value) textValue {
+	return textValue(v)
+}
+
+func (v textValue) Set(s string) error {
+	return reflect.Value(v).Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+func (v textValue) String() string {
+	val := reflect.Value(v)
+	if !val.IsValid() {
+		return ""
+	}
+	if m, ok := val.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+// ptrValue allocates the pointer on the first successful Set.
+type ptrValue reflect.Value
+
+func newPtrValue(v reflect.Value) ptrValue {
+	return ptrValue(v)
+}
+
+func (v ptrValue) Set(s string) error {
+	val := reflect.Value(v)
+	if !val.IsNil() {
+		e, _ := scalarValue(val.Elem())
+		return e.Set(s)
+	}
+	x := reflect.New(val.Type().Elem())
+	e, _ := scalarValue(x.Elem())
+	if err := e.Set(s); err != nil {
+		return err
+	}
+	val.Set(x)
+	return nil
+}
+
+func (v ptrValue) String() string {
+	val := reflect.Value(v)
+	if !val.IsValid() || val.IsNil() {
+		return ""
+	}
+	e, _ := scalarValue(val.Elem())
+	return e.String()
+}
+
+// sliceValue appends the comma separated elements on every Set, the first Set
+// replaces the default elements, an empty string sets no element.
+type sliceValue struct {
+	v   reflect.Value
+	set bool
+}
+
+func newSliceValue(v reflect.Value) *sliceValue {
+	return &sliceValue{v: v}
+}
+
+func (v *sliceValue) Set(s string) error {
+	elems := reflect.MakeSlice(v.v.Type(), 0, 0)
+	if v.set {
+		elems = v.v
+	}
+	for _, x := range split(s) {
+		e := reflect.New(v.v.Type().Elem()).Elem()
+		ev, _ := scalarValue(e)
+		if err := ev.Set(strings.TrimSpace(x)); err != nil {
+			return err
+		}
+		elems = reflect.Append(elems, e)
+	}
+	v.v.Set(elems)
+	v.set = true
+	return nil
+}
+
+func (v *sliceValue) String() string {
+	if v == nil || !v.v.IsValid() {
+		return ""
+	}
+	elems := make([]string, v.v.Len())
+	for i := range elems {
+		ev, _ := scalarValue(v.v.Index(i))
+		elems[i] = ev.String()
+	}
+	return strings.Join(elems, ",")
+}
+
+// mapValue sets the comma separated key=value pairs on every Set, the first Set
+// replaces the default pairs, an empty string sets no pair.
+type mapValue struct {
+	v   reflect.Value
+	set bool
+}
+
+func newMapValue(v reflect.Value) *mapValue {
+	return &mapValue{v: v}
+}
+
+func (v *mapValue) Set(s string) error {
+	pairs := reflect.MakeMap(v.v.Type())
+	if v.set && !v.v.IsNil() {
+		for _, k := range v.v.MapKeys() {
+			pairs.SetMapIndex(k, v.v.MapIndex(k))
+		}
+	}
+	for _, x := range split(s) {
+		kv := strings.SplitN(x, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid key=value: %s", x)
+		}
+		k := reflect.New(v.v.Type().Key()).Elem()
+		kf, _ := scalarValue(k)
+		if err := kf.Set(strings.TrimSpace(kv[0])); err != nil {
+			return err
+		}
+		e := reflect.New(v.v.Type().Elem()).Elem()
+		ef, _ := scalarValue(e)
+		if err := ef.Set(strings.TrimSpace(kv[1])); err != nil {
+			return err
+		}
+		pairs.SetMapIndex(k, e)
+	}
+	v.v.Set(pairs)
+	v.set = true
+	return nil
+}
+
+func (v *mapValue) String() string {
+	if v == nil || !v.v.IsValid() {
+		return ""
+	}
+	pairs := make([]string, 0, v.v.Len())
+	for _, k := range v.v.MapKeys() {
+		k2 := reflect.New(k.Type()).Elem()
+		k2.Set(k)
+		e := reflect.New(v.v.Type().Elem()).Elem()
+		e.Set(v.v.MapIndex(k))
+		kf, _ := scalarValue(k2)
+		ef, _ := scalarValue(e)
+		pairs = append(pairs, kf.String()+"="+ef.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// split splits the comma separated elements, it returns nil for an empty string.
+func split(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
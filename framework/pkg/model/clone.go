@@ -0,0 +1,72 @@
+package model
+
+import "reflect"
+
+// clone returns a deep copy of the value which ptr points to.
+// Unexported fields are copied shallowly.
+func clone(ptr interface{}) interface{} {
+	v := reflect.ValueOf(ptr)
+	nv := reflect.New(v.Type().Elem())
+	nv.Elem().Set(deepCopy(v.Elem()))
+	return nv.Interface()
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.New(v.Type().Elem())
+		nv.Elem().Set(deepCopy(v.Elem()))
+		return nv
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(deepCopy(v.Elem()))
+		return nv
+
+	case reflect.Struct:
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := nv.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return nv
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			nv.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return nv
+
+	case reflect.Array:
+		nv := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			nv.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return nv
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			nv.SetMapIndex(k, deepCopy(v.MapIndex(k)))
+		}
+		return nv
+
+	default:
+		return v
+	}
+}
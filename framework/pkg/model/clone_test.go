@@ -0,0 +1,57 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	type S struct {
+		A int
+	}
+	type T struct {
+		I int
+		P *S
+		L []S
+		M map[string]*S
+		A [2]*S
+		E interface{}
+		u *S
+	}
+
+	x := &T{
+		I: 1,
+		P: &S{A: 1},
+		L: []S{{A: 1}},
+		M: map[string]*S{"a": {A: 1}},
+		A: [2]*S{{A: 1}, nil},
+		E: []int{1},
+		u: &S{A: 1},
+	}
+	y := clone(x).(*T)
+	if !reflect.DeepEqual(x, y) {
+		t.Fatalf("clone: got(%v) != want(%v)", y, x)
+	}
+
+	y.I = 2
+	y.P.A = 2
+	y.L[0].A = 2
+	y.M["a"].A = 2
+	y.A[0].A = 2
+	y.E.([]int)[0] = 2
+	want := &T{
+		I: 1,
+		P: &S{A: 1},
+		L: []S{{A: 1}},
+		M: map[string]*S{"a": {A: 1}},
+		A: [2]*S{{A: 1}, nil},
+		E: []int{1},
+		u: x.u,
+	}
+	if got := x; !reflect.DeepEqual(got, want) {
+		t.Errorf("origin changed: got(%v) != want(%v)", got, want)
+	}
+	if y.u != x.u {
+		t.Errorf("unexported field not copied")
+	}
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"encoding/json"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/ironzhang/matrix/framework/pkg/tags"
 )
@@ -44,3 +47,97 @@ func typeFields(t reflect.Type) map[string]field {
 	}
 	return fields
 }
+
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var x interface{}
+	if err = json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// readonlyChanges returns the paths of the readonly fields in x which would be changed by y.
+func readonlyChanges(x reflect.Value, y interface{}, prefix string) ([]string, error) {
+	for x.Kind() == reflect.Ptr || x.Kind() == reflect.Interface {
+		if x.IsNil() {
+			return nil, nil
+		}
+		x = x.Elem()
+	}
+	m, ok := y.(map[string]interface{})
+	if x.Kind() != reflect.Struct || !ok {
+		return nil, nil
+	}
+
+	var paths []string
+	fs := typeFields(x.Type())
+	for k, v := range m {
+		f, ok := fs[k]
+		if !ok {
+			continue
+		}
+		if f.writeable {
+			sub, err := readonlyChanges(x.Field(f.index), v, prefix+k+".")
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, sub...)
+			continue
+		}
+		a, err := normalize(x.Field(f.index).Interface())
+		if err != nil {
+			return nil, err
+		}
+		b, err := normalize(v)
+		if err != nil {
+			return nil, err
+		}
+		paths = appendChanges(paths, a, b, prefix+k)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// appendChanges appends the paths of the values in b which differ from a, the keys
+// of the objects which are not present in b are not compared.
+func appendChanges(paths []string, a, b interface{}, path string) []string {
+	x, ok1 := a.(map[string]interface{})
+	y, ok2 := b.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if !reflect.DeepEqual(a, b) {
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	for k, v := range y {
+		w, ok := x[k]
+		if !ok {
+			paths = append(paths, path+"."+k)
+			continue
+		}
+		paths = appendChanges(paths, w, v, path+"."+k)
+	}
+	return paths
+}
+
+// writeable reports whether the field of t at the dotted path can be changed by Store.
+func writeable(t reflect.Type, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		f, ok := typeFields(t)[name]
+		if !ok || !f.writeable {
+			return false
+		}
+		t = f.typ
+	}
+	return true
+}
@@ -68,3 +68,100 @@ func TestTypeFields(t *testing.T) {
 		}
 	}
 }
+
+func TestReadonlyChanges(t *testing.T) {
+	type S struct {
+		A string
+		B int `json:",writeable"`
+	}
+	type T struct {
+		A string            `json:",readonly"`
+		B int               `json:",writeable"`
+		C []string          `json:"c"`
+		D map[string]string `json:"d"`
+		E S                 `json:",writeable"`
+		F S
+	}
+
+	x := T{
+		A: "a",
+		B: 1,
+		C: []string{"1", "2"},
+		D: map[string]string{"1": "1"},
+		E: S{A: "a", B: 1},
+		F: S{A: "a", B: 1},
+	}
+	tests := []struct {
+		y     map[string]interface{}
+		paths []string
+	}{
+		{
+			y:     map[string]interface{}{},
+			paths: nil,
+		},
+		{
+			y:     map[string]interface{}{"A": "a", "B": 2, "c": []interface{}{"1", "2"}, "d": map[string]interface{}{"1": "1"}},
+			paths: nil,
+		},
+		{
+			y:     map[string]interface{}{"A": "b", "B": 2, "c": []interface{}{"1"}, "d": map[string]interface{}{"1": "2"}},
+			paths: []string{"A", "c", "d.1"},
+		},
+		{
+			y:     map[string]interface{}{"E": map[string]interface{}{"A": "b", "B": 2}, "F": map[string]interface{}{"A": "a", "B": 2}},
+			paths: []string{"E.A", "F.B"},
+		},
+		{
+			y:     map[string]interface{}{"d": map[string]interface{}{"2": "2"}, "F": map[string]interface{}{"A": "a"}},
+			paths: []string{"d.2"},
+		},
+		{
+			y:     map[string]interface{}{"F": map[string]interface{}{"A": "b"}},
+			paths: []string{"F.A"},
+		},
+	}
+	for i, tt := range tests {
+		paths, err := readonlyChanges(reflect.ValueOf(&x), tt.y, "")
+		if err != nil {
+			t.Errorf("tests[%d]: readonly changes: %v", i, err)
+			continue
+		}
+		if got, want := paths, tt.paths; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: got(%v) != want(%v)", i, got, want)
+		}
+	}
+}
+
+func TestWriteable(t *testing.T) {
+	type S struct {
+		A string
+		B int `json:",writeable"`
+	}
+	type T struct {
+		A string `json:",readonly"`
+		B int    `json:"b,writeable"`
+		E S      `json:",writeable"`
+		F S
+		P *S `json:",writeable"`
+	}
+
+	tests := []struct {
+		path      string
+		writeable bool
+	}{
+		{path: "A", writeable: false},
+		{path: "b", writeable: true},
+		{path: "B", writeable: false},
+		{path: "E.A", writeable: false},
+		{path: "E.B", writeable: true},
+		{path: "F.B", writeable: false},
+		{path: "P.B", writeable: true},
+		{path: "X", writeable: false},
+		{path: "b.X", writeable: false},
+	}
+	for i, tt := range tests {
+		if got, want := writeable(reflect.TypeOf(&T{}), tt.path), tt.writeable; got != want {
+			t.Errorf("tests[%d]: %s: got(%v) != want(%v)", i, tt.path, got, want)
+		}
+	}
+}
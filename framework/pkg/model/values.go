@@ -1,32 +1,123 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 type Reloader interface {
 	Reload() error
 }
 
+type Validator interface {
+	Validate() error
+}
+
 type Value struct {
-	mu  sync.Mutex
-	ptr interface{}
+	mu   sync.Mutex
+	ptr  interface{}
+	snap atomic.Value
 }
 
+// Load returns the registered pointer, which holds the value loaded at startup.
+// It is not updated by Store, the current value is read by Snapshot.
 func (v *Value) Load() interface{} {
 	return v.ptr
 }
 
+// Snapshot returns a consistent copy of the current value, which must not be modified.
+func (v *Value) Snapshot() interface{} {
+	if x := v.snap.Load(); x != nil {
+		return x
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.current()
+}
+
+// Seal takes the snapshot of the loaded value. Before it the snapshots are copied from
+// the registered pointer on each call, after it the registered pointer must not be written.
+func (v *Value) Seal() {
+	v.mu.Lock()
+	v.snap.Store(clone(v.ptr))
+	v.mu.Unlock()
+}
+
+func (v *Value) current() interface{} {
+	if x := v.snap.Load(); x != nil {
+		return x
+	}
+	return clone(v.ptr)
+}
+
+// Store applies a to a copy of the current value, validates and reloads the copy,
+// and only then swaps it in as the new snapshot. The registered pointer is never
+// written, and the value is left unchanged on error.
 func (v *Value) Store(a interface{}) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	return setValue(v.ptr, a)
+
+	cur := v.current()
+	x := clone(cur)
+	if err := setValue(x, a); err != nil {
+		return err
+	}
+	if c, ok := x.(Validator); ok {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("validate: %v", err)
+		}
+	}
+	if r, ok := x.(Reloader); ok {
+		if err := r.Reload(); err != nil {
+			if e := cur.(Reloader).Reload(); e != nil {
+				return fmt.Errorf("reload: %v, restore: %v", err, e)
+			}
+			return fmt.Errorf("reload: %v", err)
+		}
+	}
+	v.snap.Store(x)
+	return nil
+}
+
+// Update applies a to a copy of the registered value, validates the copy, and then
+// writes it to the registered pointer. It is for the values read by the registered
+// pointer, such as the options, and fails after Seal.
+func (v *Value) Update(a interface{}) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.snap.Load() != nil {
+		return errors.New("value sealed")
+	}
+	x := clone(v.ptr)
+	if err := setValue(x, a); err != nil {
+		return err
+	}
+	if c, ok := x.(Validator); ok {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("validate: %v", err)
+		}
+	}
+	reflect.ValueOf(v.ptr).Elem().Set(reflect.ValueOf(x).Elem())
+	return nil
+}
+
+func (v *Value) ReadonlyChanges(a interface{}) ([]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return readonlyChanges(reflect.ValueOf(v.current()), a, "")
+}
+
+func (v *Value) Writeable(path string) bool {
+	return writeable(reflect.TypeOf(v.ptr), path)
 }
 
 func (v *Value) Reload() error {
-	if r, ok := v.ptr.(Reloader); ok {
+	if r, ok := v.Snapshot().(Reloader); ok {
 		return r.Reload()
 	}
 	return nil
@@ -65,6 +156,28 @@ func (p *Values) GetInterface(name string) (interface{}, bool) {
 	return nil, false
 }
 
+func (p *Values) GetSnapshot(name string) (interface{}, bool) {
+	if v, ok := p.m[name]; ok {
+		return v.Snapshot(), true
+	}
+	return nil, false
+}
+
+func (p *Values) Snapshots() map[string]interface{} {
+	m := make(map[string]interface{})
+	for k, v := range p.m {
+		m[k] = v.Snapshot()
+	}
+	return m
+}
+
+// Seal seals all the values, it is called after the values are loaded.
+func (p *Values) Seal() {
+	for _, v := range p.m {
+		v.Seal()
+	}
+}
+
 func (p *Values) Interfaces() map[string]interface{} {
 	m := make(map[string]interface{})
 	for k, v := range p.m {
@@ -72,3 +185,20 @@ func (p *Values) Interfaces() map[string]interface{} {
 	}
 	return m
 }
+
+// Validate validates all the values which implement Validator.
+func (p *Values) Validate() error {
+	names := make([]string, 0, len(p.m))
+	for k := range p.m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c, ok := p.m[name].ptr.(Validator); ok {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("validate %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package model
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -40,3 +42,222 @@ func TestValues(t *testing.T) {
 		t.Errorf("value: got(%v) != want(%v)", got, want)
 	}
 }
+
+type testConfig struct {
+	A       int   `json:",writeable"`
+	B       []int `json:",writeable"`
+	reloads int
+	fail    bool
+}
+
+func (c *testConfig) Validate() error {
+	if c.A < 0 {
+		return fmt.Errorf("negative A: %d", c.A)
+	}
+	return nil
+}
+
+func (c *testConfig) Reload() error {
+	if c.fail && c.A > 10 {
+		return fmt.Errorf("reload fail")
+	}
+	c.reloads++
+	return nil
+}
+
+func TestValueStore(t *testing.T) {
+	tests := []struct {
+		fail bool
+		a    map[string]interface{}
+		err  string
+		want testConfig
+	}{
+		{
+			a:    map[string]interface{}{"A": 1, "B": []interface{}{1}},
+			want: testConfig{A: 1, B: []int{1}, reloads: 1},
+		},
+		{
+			a:    map[string]interface{}{"A": -1, "B": []interface{}{1}},
+			err:  "validate: negative A: -1",
+			want: testConfig{B: []int{0}},
+		},
+		{
+			fail: true,
+			a:    map[string]interface{}{"A": 11, "B": []interface{}{1}},
+			err:  "reload: reload fail",
+			want: testConfig{B: []int{0}, reloads: 1, fail: true},
+		},
+		{
+			a:    map[string]interface{}{"A": "x"},
+			err:  "reflect.Set: value of type string is not assignable to type int",
+			want: testConfig{B: []int{0}},
+		},
+	}
+	for i, tt := range tests {
+		c := &testConfig{B: []int{0}, fail: tt.fail}
+		v := Value{ptr: c}
+		v.Seal()
+		err := v.Store(tt.a)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("tests[%d]: store: %v", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("tests[%d]: error: got(%v) != want(%s)", i, err, tt.err)
+		}
+		if got, want := *v.Snapshot().(*testConfig), tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: value: got(%+v) != want(%+v)", i, got, want)
+		}
+		if got, want := *c, (testConfig{B: []int{0}, fail: tt.fail}); !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: registered value changed: got(%+v) != want(%+v)", i, got, want)
+		}
+	}
+}
+
+func TestValueUpdate(t *testing.T) {
+	tests := []struct {
+		seal bool
+		a    map[string]interface{}
+		err  string
+		want testConfig
+	}{
+		{
+			a:    map[string]interface{}{"A": 1, "B": []interface{}{1}},
+			want: testConfig{A: 1, B: []int{1}},
+		},
+		{
+			a:    map[string]interface{}{"A": -1, "B": []interface{}{1}},
+			err:  "validate: negative A: -1",
+			want: testConfig{B: []int{0}},
+		},
+		{
+			seal: true,
+			a:    map[string]interface{}{"A": 1},
+			err:  "value sealed",
+			want: testConfig{B: []int{0}},
+		},
+	}
+	for i, tt := range tests {
+		c := &testConfig{B: []int{0}}
+		v := Value{ptr: c}
+		if tt.seal {
+			v.Seal()
+		}
+		err := v.Update(tt.a)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("tests[%d]: update: %v", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("tests[%d]: error: got(%v) != want(%s)", i, err, tt.err)
+		}
+		if got, want := *c, tt.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: registered value: got(%+v) != want(%+v)", i, got, want)
+		}
+	}
+}
+
+func TestValueSnapshot(t *testing.T) {
+	c := &testConfig{A: 1, B: []int{1}}
+	v := Value{ptr: c}
+
+	s1 := v.Snapshot().(*testConfig)
+	if got, want := *s1, *c; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot: got(%+v) != want(%+v)", got, want)
+	}
+	if s1 == c {
+		t.Errorf("snapshot is the registered pointer")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s := v.Snapshot().(*testConfig)
+			if len(s.B) != s.A {
+				t.Errorf("torn snapshot: %+v", s)
+				return
+			}
+		}
+	}()
+	for i := 2; i < 100; i++ {
+		b := make([]interface{}, i)
+		for j := range b {
+			b[j] = j
+		}
+		if err := v.Store(map[string]interface{}{"A": i, "B": b}); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+	<-done
+
+	if got, want := *s1, (testConfig{A: 1, B: []int{1}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("old snapshot changed: got(%+v) != want(%+v)", got, want)
+	}
+	s2 := v.Snapshot().(*testConfig)
+	if got, want := s2.A, 99; got != want {
+		t.Errorf("new snapshot: got(%d) != want(%d)", got, want)
+	}
+	if got, want := *c, (testConfig{A: 1, B: []int{1}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("registered value changed: got(%+v) != want(%+v)", got, want)
+	}
+}
+
+func TestValueSeal(t *testing.T) {
+	c := &testConfig{A: 1, B: []int{1}}
+	v := Value{ptr: c}
+
+	// the snapshots follow the registered pointer until the value is sealed
+	v.Snapshot()
+	c.A, c.B = 2, []int{1, 2}
+	if got, want := *v.Snapshot().(*testConfig), (testConfig{A: 2, B: []int{1, 2}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot before seal: got(%+v) != want(%+v)", got, want)
+	}
+	v.Seal()
+	if got, want := *v.Snapshot().(*testConfig), (testConfig{A: 2, B: []int{1, 2}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot after seal: got(%+v) != want(%+v)", got, want)
+	}
+}
+
+func TestValueStoreRace(t *testing.T) {
+	c := &testConfig{A: 1, B: []int{0}}
+	v := Value{ptr: c}
+	v.Seal()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := v.Snapshot().(*testConfig)
+				sum := 0
+				for _, b := range s.B {
+					sum += b
+				}
+				if len(s.B) != s.A || sum != s.A*(s.A-1)/2 {
+					t.Errorf("torn snapshot: %+v", s)
+					return
+				}
+				_ = c.A + len(c.B)
+			}
+		}()
+	}
+	for i := 2; i < 200; i++ {
+		b := make([]interface{}, i)
+		for j := range b {
+			b[j] = j
+		}
+		if err := v.Store(map[string]interface{}{"A": i, "B": b}); err != nil {
+			t.Errorf("store: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
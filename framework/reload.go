@@ -0,0 +1,137 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ironzhang/matrix/jsoncfg"
+	"github.com/ironzhang/matrix/tlog"
+)
+
+func (f *framework) reloadAppConfig() error {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+
+	file := f.options.ConfigFile
+	if file == "" {
+		return nil
+	}
+	if err := f.checkAppConfig(); err != nil {
+		return err
+	}
+	m, err := loadSections(f.readFile, f.getenv, file)
+	if err != nil {
+		return err
+	}
+
+	if f.sections == nil {
+		f.sections = make(map[string]byteSlice)
+	}
+	log := tlog.Std().Sugar().With("file", file)
+	for name, data := range m {
+		if bytes.Equal(data, f.sections[name]) {
+			continue
+		}
+		v, ok := f.configs.GetValue(name)
+		if !ok {
+			continue
+		}
+		var a interface{}
+		if err := json.Unmarshal(data, &a); err != nil {
+			return fmt.Errorf("unmarshal %s module config: %v", name, err)
+		}
+		if section, ok := a.(map[string]interface{}); ok {
+			for path, value := range f.envConfigs[name] {
+				setPath(section, path, value)
+			}
+		}
+		paths, err := v.ReadonlyChanges(a)
+		if err != nil {
+			return fmt.Errorf("check %s module config: %v", name, err)
+		}
+		if len(paths) > 0 {
+			log.Warnw("readonly fields changed, ignored", "module", name, "fields", paths)
+		}
+		if err = v.Store(a); err != nil {
+			return fmt.Errorf("store %s module config: %v", name, err)
+		}
+		f.sections[name] = data
+		config, err := jsoncfg.Redact(v.Snapshot())
+		if err != nil {
+			return err
+		}
+		log.Infow("reload", "module", name, "config", config)
+	}
+	return nil
+}
+
+func (f *framework) reload(reason string) {
+	log := tlog.Std().Sugar().With("reason", reason, "file", f.options.ConfigFile)
+	if err := f.reloadAppConfig(); err != nil {
+		log.Errorw("reload app config", "error", err)
+		return
+	}
+	log.Debug("reload app config")
+}
+
+// layerFiles returns the config files and the files they include.
+func layerFiles(read readFunc, file string) ([]string, error) {
+	layers, err := readLayers(read, configFiles(file))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(layers))
+	for _, l := range layers {
+		files = append(files, l.file)
+	}
+	return files, nil
+}
+
+func (f *framework) watchConfigFile(ctx context.Context, interval time.Duration) {
+	log := tlog.Std().Sugar().With("file", f.options.ConfigFile)
+	files, err := layerFiles(f.readFile, f.options.ConfigFile)
+	if err != nil {
+		log.Errorw("read config files", "error", err)
+		files = configFiles(f.options.ConfigFile)
+	}
+	last := make(map[string]os.FileInfo)
+	for _, file := range files {
+		if last[file], err = os.Stat(file); err != nil {
+			log.Errorw("stat", "file", file, "error", err)
+		}
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			var changed bool
+			for _, file := range files {
+				fi, err := os.Stat(file)
+				if err != nil {
+					log.Errorw("stat", "file", file, "error", err)
+					continue
+				}
+				if l := last[file]; l != nil && fi.ModTime().Equal(l.ModTime()) && fi.Size() == l.Size() {
+					continue
+				}
+				last[file] = fi
+				changed = true
+			}
+			if !changed {
+				continue
+			}
+			f.reload("file changed")
+			if fs, err := layerFiles(f.readFile, f.options.ConfigFile); err == nil {
+				files = fs
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package framework
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testConfig struct {
+	Addr    string `json:",readonly"`
+	Verbose int    `json:",writeable"`
+	reloads int
+}
+
+func (c *testConfig) Reload() error {
+	c.reloads++
+	return nil
+}
+
+func snapshotOf(f *framework, name string) testConfig {
+	c, _ := f.configs.GetSnapshot(name)
+	return *c.(*testConfig)
+}
+
+func TestReloadAppConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	var f framework
+	a := &testConfig{Addr: ":6060"}
+	b := &testConfig{Addr: ":7070"}
+	f.configs.Register("a", a)
+	f.configs.Register("b", b)
+	f.options.ConfigFile = file.Name()
+
+	write := func(s string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"a": {"Addr": ":6060", "Verbose": 1}, "b": {"Addr": ":7070", "Verbose": 1}}`)
+	if f.sections, err = loadAppConfig(&f.configs, nil, nil, file.Name()); err != nil {
+		t.Fatalf("load app config: %v", err)
+	}
+	f.configs.Seal()
+
+	write(`{"a": {"Addr": ":6061", "Verbose": 2}, "b": {"Addr": ":7070", "Verbose": 1}}`)
+	if err = f.reloadAppConfig(); err != nil {
+		t.Fatalf("reload app config: %v", err)
+	}
+
+	want := testConfig{Addr: ":6060", Verbose: 2, reloads: 1}
+	if got := snapshotOf(&f, "a"); got != want {
+		t.Errorf("a: got(%+v) != want(%+v)", got, want)
+	}
+	if got := *a; got != (testConfig{Addr: ":6060", Verbose: 1}) {
+		t.Errorf("a: registered value changed: %+v", got)
+	}
+	want = testConfig{Addr: ":7070", Verbose: 1, reloads: 0}
+	if got := snapshotOf(&f, "b"); got != want {
+		t.Errorf("b: got(%+v) != want(%+v)", got, want)
+	}
+}
+
+func TestLoadAppConfigFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "formats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file string
+		data string
+	}{
+		{
+			file: "cfg.json",
+			data: `{"a": {"Addr": ":6061", "Verbose": 1}}`,
+		},
+		{
+			file: "cfg.yaml",
+			data: "a:\n  Addr: \":6061\"\n  Verbose: 1\n",
+		},
+		{
+			file: "cfg.toml",
+			data: "[a]\nAddr = \":6061\"\nVerbose = 1\n",
+		},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(dir, tt.file)
+		if err = ioutil.WriteFile(file, []byte(tt.data), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		var f framework
+		a := &testConfig{Addr: ":6060"}
+		f.configs.Register("a", a)
+		f.options.ConfigFile = file
+		if f.sections, err = loadAppConfig(&f.configs, nil, nil, file); err != nil {
+			t.Errorf("tests[%d]: load app config: %v", i, err)
+			continue
+		}
+		if got, want := *a, (testConfig{Addr: ":6061", Verbose: 1}); got != want {
+			t.Errorf("tests[%d]: load: got(%+v) != want(%+v)", i, got, want)
+		}
+
+		f.configs.Seal()
+		v, _ := f.configs.GetValue("a")
+		prev := v.Snapshot()
+		if err = v.Store(map[string]interface{}{"Verbose": 2}); err != nil {
+			t.Fatalf("tests[%d]: store: %v", i, err)
+		}
+		if err = f.saveAppConfig("a", prev); err != nil {
+			if filepath.Ext(file) != ".toml" {
+				t.Errorf("tests[%d]: save app config: %v", i, err)
+			}
+			continue
+		}
+		if filepath.Ext(file) == ".toml" {
+			t.Errorf("tests[%d]: save to toml expect error but not", i)
+		}
+		b := &testConfig{}
+		var g framework
+		g.configs.Register("a", b)
+		if _, err = loadAppConfig(&g.configs, nil, nil, file); err != nil {
+			t.Errorf("tests[%d]: reload saved app config: %v", i, err)
+			continue
+		}
+		if got, want := *b, (testConfig{Addr: ":6061", Verbose: 2}); got != want {
+			t.Errorf("tests[%d]: saved: got(%+v) != want(%+v)", i, got, want)
+		}
+	}
+}
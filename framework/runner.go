@@ -0,0 +1,329 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/matrix/tlog"
+)
+
+type GracefulRunner interface {
+	Runner
+	StopTimeout() time.Duration
+}
+
+type Policy int
+
+const (
+	StopOnFailure Policy = iota
+	RestartOnFailure
+	IgnoreFailure
+)
+
+var policyNames = map[Policy]string{
+	StopOnFailure:    "stop",
+	RestartOnFailure: "restart",
+	IgnoreFailure:    "ignore",
+}
+
+func (p Policy) String() string {
+	if s, ok := policyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("policy(%d)", int(p))
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Policy) UnmarshalText(b []byte) error {
+	for k, v := range policyNames {
+		if v == string(b) {
+			*p = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy: %s", b)
+}
+
+type RestartPolicy struct {
+	Policy      Policy
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	MaxRestarts int
+}
+
+func (p RestartPolicy) minBackoff() time.Duration {
+	if p.MinBackoff <= 0 {
+		return time.Second
+	}
+	return p.MinBackoff
+}
+
+func (p RestartPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff < p.minBackoff() {
+		return 64 * p.minBackoff()
+	}
+	return p.MaxBackoff
+}
+
+// parseRestartPolicy parses the policy in the format of policy[:min backoff[:max backoff[:max restarts]]],
+// the omitted or empty parts are zero.
+func parseRestartPolicy(s string) (p RestartPolicy, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 4 {
+		return p, fmt.Errorf("invalid restart policy: %s", s)
+	}
+	if err = p.Policy.UnmarshalText([]byte(parts[0])); err != nil {
+		return p, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if p.MinBackoff, err = time.ParseDuration(parts[1]); err != nil {
+			return p, fmt.Errorf("min backoff: %v", err)
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if p.MaxBackoff, err = time.ParseDuration(parts[2]); err != nil {
+			return p, fmt.Errorf("max backoff: %v", err)
+		}
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		if p.MaxRestarts, err = strconv.Atoi(parts[3]); err != nil {
+			return p, fmt.Errorf("max restarts: %v", err)
+		}
+	}
+	return p, nil
+}
+
+type Supervised interface {
+	RestartPolicy() RestartPolicy
+}
+
+type RunnerStatus struct {
+	Module    string
+	Policy    Policy
+	Running   bool
+	Restarts  int
+	LastError string
+}
+
+type runner struct {
+	module Module
+	policy RestartPolicy
+	status *moduleStatus
+	done   chan struct{}
+
+	mu       sync.Mutex
+	restarts int
+	err      error
+}
+
+// startRunners starts the runners of the modules, status returns the status of a module.
+func startRunners(ctx context.Context, modules []Module, policies map[string]RestartPolicy, status func(string) *moduleStatus, stop func(error)) []*runner {
+	var runners []*runner
+	for _, m := range modules {
+		if _, ok := m.(Runner); ok {
+			r := &runner{module: m, status: status(m.Name()), done: make(chan struct{})}
+			if p, ok := policies[m.Name()]; ok {
+				r.policy = p
+			} else if s, ok := m.(Supervised); ok {
+				r.policy = s.RestartPolicy()
+			}
+			go r.run(ctx, stop)
+			runners = append(runners, r)
+		}
+	}
+	return runners
+}
+
+func (r *runner) run(ctx context.Context, stop func(error)) {
+	defer close(r.done)
+
+	log := tlog.Std().Sugar().With("module", r.module.Name(), "policy", r.policy.Policy)
+	backoff := r.policy.minBackoff()
+	for {
+		start := time.Now()
+		r.status.running()
+		err := r.module.(Runner).Run(ctx)
+		if ctx.Err() != nil {
+			if err != nil {
+				log.Warnw("run", "error", err)
+			}
+			r.status.exited(nil)
+			return
+		}
+		r.status.exited(err)
+		if err == nil {
+			log.Infow("runner exit")
+			return
+		}
+		r.setError(err)
+
+		switch r.policy.Policy {
+		case RestartOnFailure:
+			restarts := r.Restarts()
+			if r.policy.MaxRestarts > 0 && restarts >= r.policy.MaxRestarts {
+				log.Errorw("run", "error", err, "restarts", restarts)
+				stop(fmt.Errorf("run %s: %v, restarts %d", r.module.Name(), err, restarts))
+				return
+			}
+			if time.Since(start) > r.policy.maxBackoff() {
+				backoff = r.policy.minBackoff()
+			}
+			log.Errorw("run", "error", err, "restarts", restarts, "backoff", backoff)
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+			if backoff *= 2; backoff > r.policy.maxBackoff() {
+				backoff = r.policy.maxBackoff()
+			}
+			r.incRestarts()
+			log.Infow("restart", "restarts", restarts+1)
+
+		case IgnoreFailure:
+			log.Errorw("run", "error", err)
+			return
+
+		default:
+			log.Errorw("run", "error", err)
+			stop(fmt.Errorf("run %s: %v", r.module.Name(), err))
+			return
+		}
+	}
+}
+
+func (r *runner) setError(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+func (r *runner) incRestarts() {
+	r.mu.Lock()
+	r.restarts++
+	r.mu.Unlock()
+}
+
+func (r *runner) Restarts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restarts
+}
+
+func (r *runner) Status() RunnerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := RunnerStatus{
+		Module:   r.module.Name(),
+		Policy:   r.policy.Policy,
+		Running:  !r.stopped(),
+		Restarts: r.restarts,
+	}
+	if r.err != nil {
+		s.LastError = r.err.Error()
+	}
+	return s
+}
+
+func (r *runner) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *runner) watchDeadline() {
+	g, ok := r.module.(GracefulRunner)
+	if !ok || g.StopTimeout() <= 0 {
+		return
+	}
+	t := time.NewTimer(g.StopTimeout())
+	defer t.Stop()
+	select {
+	case <-r.done:
+	case <-t.C:
+		tlog.Std().Sugar().Warnw("runner not stop in time", "module", r.module.Name(), "deadline", g.StopTimeout())
+	}
+}
+
+func waitRunners(ctx context.Context, runners []*runner, timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		for _, r := range runners {
+			<-r.done
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	for _, r := range runners {
+		go r.watchDeadline()
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-t.C:
+		var pending []string
+		for _, r := range runners {
+			if !r.stopped() {
+				pending = append(pending, r.module.Name())
+			}
+		}
+		tlog.Std().Sugar().Errorw("runners not stop in time", "modules", pending, "timeout", timeout)
+		return fmt.Errorf("shutdown timeout(%s): %s not stop", timeout, strings.Join(pending, ", "))
+	}
+}
+
+// stoppedModules returns the modules which can be finied after the runners timed out,
+// which are neither the modules of the runners not stopped nor their dependencies.
+func stoppedModules(modules []Module, runners []*runner) []Module {
+	index := make(map[string]Module, len(modules))
+	for _, m := range modules {
+		index[m.Name()] = m
+	}
+	busy := make(map[string]bool)
+	var mark func(name string)
+	mark = func(name string) {
+		if busy[name] {
+			return
+		}
+		busy[name] = true
+		if m, ok := index[name]; ok {
+			for _, d := range dependsOf(m) {
+				mark(d)
+			}
+		}
+	}
+	for _, r := range runners {
+		if !r.stopped() {
+			mark(r.module.Name())
+		}
+	}
+
+	var stopped []Module
+	for _, m := range modules {
+		if !busy[m.Name()] {
+			stopped = append(stopped, m)
+		}
+	}
+	return stopped
+}
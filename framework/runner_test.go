@@ -0,0 +1,205 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testRunner struct {
+	testModule
+	delay   time.Duration
+	timeout time.Duration
+}
+
+func (r *testRunner) Run(ctx context.Context) error {
+	<-ctx.Done()
+	time.Sleep(r.delay)
+	return nil
+}
+
+func (r *testRunner) StopTimeout() time.Duration {
+	return r.timeout
+}
+
+func newStatus(string) *moduleStatus {
+	return &moduleStatus{}
+}
+
+func TestWaitRunners(t *testing.T) {
+	tests := []struct {
+		delays []time.Duration
+		err    string
+	}{
+		{
+			delays: []time.Duration{0, 10 * time.Millisecond},
+			err:    "",
+		},
+		{
+			delays: []time.Duration{0, time.Second, 10 * time.Millisecond, time.Second},
+			err:    "shutdown timeout(100ms): r1, r3 not stop",
+		},
+	}
+	for i, tt := range tests {
+		var modules []Module
+		for j, d := range tt.delays {
+			r := &testRunner{delay: d, timeout: 50 * time.Millisecond}
+			r.name = fmt.Sprintf("r%d", j)
+			modules = append(modules, r)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runners := startRunners(ctx, modules, nil, newStatus, func(error) {})
+		cancel()
+
+		err := waitRunners(ctx, runners, 100*time.Millisecond)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("tests[%d]: wait runners: %v", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("tests[%d]: wait runners expect error but not", i)
+			continue
+		}
+		if got, want := err.Error(), tt.err; got != want {
+			t.Errorf("tests[%d]: error: %q != %q", i, got, want)
+		}
+	}
+}
+
+type failRunner struct {
+	testModule
+	fails  int
+	calls  int
+	policy RestartPolicy
+}
+
+func (r *failRunner) Run(ctx context.Context) error {
+	r.calls++
+	if r.calls <= r.fails {
+		return fmt.Errorf("fail %d", r.calls)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (r *failRunner) RestartPolicy() RestartPolicy {
+	return r.policy
+}
+
+func TestRunnerPolicy(t *testing.T) {
+	backoff := time.Millisecond
+	tests := []struct {
+		fails    int
+		policy   RestartPolicy
+		restarts int
+		stopped  bool
+		state    ModuleState
+		err      string
+	}{
+		{
+			fails:    0,
+			policy:   RestartPolicy{Policy: StopOnFailure},
+			restarts: 0,
+			stopped:  false,
+			state:    Stopped,
+		},
+		{
+			fails:    1,
+			policy:   RestartPolicy{Policy: StopOnFailure},
+			restarts: 0,
+			stopped:  true,
+			state:    Failed,
+			err:      "run r: fail 1",
+		},
+		{
+			fails:    1,
+			policy:   RestartPolicy{Policy: IgnoreFailure},
+			restarts: 0,
+			stopped:  false,
+			state:    Failed,
+			err:      "fail 1",
+		},
+		{
+			fails:    3,
+			policy:   RestartPolicy{Policy: RestartOnFailure, MinBackoff: backoff, MaxBackoff: 4 * backoff},
+			restarts: 3,
+			stopped:  false,
+			state:    Stopped,
+			err:      "fail 3",
+		},
+		{
+			fails:    3,
+			policy:   RestartPolicy{Policy: RestartOnFailure, MinBackoff: backoff, MaxRestarts: 2},
+			restarts: 2,
+			stopped:  true,
+			state:    Failed,
+			err:      "run r: fail 3, restarts 2",
+		},
+	}
+	for i, tt := range tests {
+		r := &failRunner{fails: tt.fails, policy: tt.policy}
+		r.name = "r"
+
+		var stopErr error
+		s := &moduleStatus{}
+		ctx, cancel := context.WithCancel(context.Background())
+		runners := startRunners(ctx, []Module{r}, nil, func(string) *moduleStatus { return s }, func(err error) {
+			stopErr = err
+			cancel()
+		})
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		if err := waitRunners(ctx, runners, time.Second); err != nil {
+			t.Fatalf("tests[%d]: wait runners: %v", i, err)
+		}
+
+		status := runners[0].Status()
+		if got, want := status.Restarts, tt.restarts; got != want {
+			t.Errorf("tests[%d]: restarts: %v != %v", i, got, want)
+		}
+		if got, want := s.status(r).State, tt.state; got != want {
+			t.Errorf("tests[%d]: state: %v != %v", i, got, want)
+		}
+		if got, want := stopErr != nil, tt.stopped; got != want {
+			t.Errorf("tests[%d]: stopped: %v != %v", i, got, want)
+		}
+		if stopErr != nil {
+			if got, want := stopErr.Error(), tt.err; got != want {
+				t.Errorf("tests[%d]: stop error: %q != %q", i, got, want)
+			}
+		} else if got, want := status.LastError, tt.err; got != want {
+			t.Errorf("tests[%d]: last error: %q != %q", i, got, want)
+		}
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		s      string
+		policy RestartPolicy
+		err    bool
+	}{
+		{s: "stop", policy: RestartPolicy{Policy: StopOnFailure}},
+		{s: "ignore", policy: RestartPolicy{Policy: IgnoreFailure}},
+		{s: "restart:1s", policy: RestartPolicy{Policy: RestartOnFailure, MinBackoff: time.Second}},
+		{s: "restart::1m:5", policy: RestartPolicy{Policy: RestartOnFailure, MaxBackoff: time.Minute, MaxRestarts: 5}},
+		{s: "restart:1s:1m:5:1", err: true},
+		{s: "restart:1x", err: true},
+		{s: "restart:1s:1m:x", err: true},
+		{s: "", err: true},
+	}
+	for i, tt := range tests {
+		p, err := parseRestartPolicy(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("tests[%d]: error: %v", i, err)
+			continue
+		}
+		if err == nil && p != tt.policy {
+			t.Errorf("tests[%d]: got(%+v) != want(%+v)", i, p, tt.policy)
+		}
+	}
+}
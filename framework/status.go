@@ -0,0 +1,161 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/matrix/jsoncfg"
+)
+
+type ModuleState int
+
+const (
+	Registered ModuleState = iota
+	Initialized
+	Running
+	Stopped
+	Failed
+)
+
+var stateNames = map[ModuleState]string{
+	Registered:  "registered",
+	Initialized: "initialized",
+	Running:     "running",
+	Stopped:     "stopped",
+	Failed:      "failed",
+}
+
+func (s ModuleState) String() string {
+	if n, ok := stateNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+func (s ModuleState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ModuleState) UnmarshalText(b []byte) error {
+	for k, v := range stateNames {
+		if v == string(b) {
+			*s = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown state: %s", b)
+}
+
+// ModuleStatus is the lifecycle of a module. The modules which are not runners are running
+// from the start of the runners until they are finied, the uptime is the time since the
+// module was inited.
+type ModuleStatus struct {
+	Module       string
+	State        ModuleState
+	Runner       bool
+	InitDuration jsoncfg.Duration
+	FiniDuration jsoncfg.Duration
+	LastError    string
+	InitTime     time.Time
+	Uptime       jsoncfg.Duration
+}
+
+type moduleStatus struct {
+	mu       sync.Mutex
+	state    ModuleState
+	initDur  time.Duration
+	finiDur  time.Duration
+	err      error
+	initTime time.Time
+	finiTime time.Time
+}
+
+func (s *moduleStatus) inited(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.initDur = d
+	if err != nil {
+		s.state, s.err = Failed, err
+		return
+	}
+	s.state, s.initTime = Initialized, time.Now()
+}
+
+func (s *moduleStatus) finied(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finiDur, s.finiTime = d, time.Now()
+	if err != nil {
+		s.state, s.err = Failed, err
+		return
+	}
+	if s.state != Failed {
+		s.state = Stopped
+	}
+}
+
+func (s *moduleStatus) running() {
+	s.mu.Lock()
+	s.state = Running
+	s.mu.Unlock()
+}
+
+// exited records the return of Run, a nil err means the runner is done.
+func (s *moduleStatus) exited(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.state, s.err = Failed, err
+		return
+	}
+	s.state = Stopped
+}
+
+func (s *moduleStatus) status(m Module) ModuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, runner := m.(Runner)
+	status := ModuleStatus{
+		Module:       m.Name(),
+		State:        s.state,
+		Runner:       runner,
+		InitDuration: jsoncfg.Duration(s.initDur),
+		FiniDuration: jsoncfg.Duration(s.finiDur),
+		InitTime:     s.initTime,
+	}
+	if s.err != nil {
+		status.LastError = s.err.Error()
+	}
+	switch {
+	case s.initTime.IsZero():
+	case !s.finiTime.IsZero():
+		status.Uptime = jsoncfg.Duration(s.finiTime.Sub(s.initTime))
+	default:
+		status.Uptime = jsoncfg.Duration(time.Since(s.initTime))
+	}
+	return status
+}
+
+func (f *framework) moduleStatus(name string) *moduleStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.statuses == nil {
+		f.statuses = make(map[string]*moduleStatus)
+	}
+	s, ok := f.statuses[name]
+	if !ok {
+		s = &moduleStatus{}
+		f.statuses[name] = s
+	}
+	return s
+}
+
+// ModuleStatuses returns the status of the registered modules in the order of registration.
+func (f *framework) ModuleStatuses() []ModuleStatus {
+	statuses := make([]ModuleStatus, 0, len(f.modules))
+	for _, m := range f.modules {
+		statuses = append(statuses, f.moduleStatus(m.Name()).status(m))
+	}
+	return statuses
+}
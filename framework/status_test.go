@@ -0,0 +1,110 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func moduleStates(statuses []ModuleStatus) map[string]ModuleState {
+	states := make(map[string]ModuleState)
+	for _, s := range statuses {
+		states[s.Module] = s.State
+	}
+	return states
+}
+
+func waitStates(t *testing.T, app *App, want map[string]ModuleState) []ModuleStatus {
+	var statuses []ModuleStatus
+	for i := 0; i < 100; i++ {
+		statuses = app.ModuleStatuses()
+		if got := moduleStates(statuses); len(got) == len(want) {
+			equal := true
+			for k, v := range want {
+				equal = equal && got[k] == v
+			}
+			if equal {
+				return statuses
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("states: got(%v) != want(%v)", moduleStates(statuses), want)
+	return statuses
+}
+
+func TestModuleStatuses(t *testing.T) {
+	var events []string
+	app := New(AppOptions{})
+	b := &appModule{events: &events}
+	b.name, b.depends = "b", []string{"a"}
+	c := &appModule{events: &events, runErr: errors.New("bad run")}
+	c.name = "c"
+	for _, m := range []Module{&testModule{name: "a"}, b, c} {
+		if err := app.Register(m, nil, nil); err != nil {
+			t.Fatalf("register %s: %v", m.Name(), err)
+		}
+	}
+	app.SetRestartPolicy("c", RestartPolicy{Policy: IgnoreFailure})
+
+	waitStates(t, app, map[string]ModuleState{"a": Registered, "b": Registered, "c": Registered})
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	statuses := waitStates(t, app, map[string]ModuleState{"a": Running, "b": Running, "c": Failed})
+	for _, s := range statuses {
+		if s.Runner != (s.Module != "a") {
+			t.Errorf("%s: runner: got(%v)", s.Module, s.Runner)
+		}
+		if s.InitTime.IsZero() || s.Uptime <= 0 {
+			t.Errorf("%s: init time(%v), uptime(%v)", s.Module, s.InitTime, s.Uptime)
+		}
+	}
+	if got, want := statuses[2].LastError, "bad run"; got != want {
+		t.Errorf("c: last error: got(%q) != want(%q)", got, want)
+	}
+
+	if err := app.Stop(); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+	statuses = waitStates(t, app, map[string]ModuleState{"a": Stopped, "b": Stopped, "c": Failed})
+	uptime := statuses[0].Uptime
+	time.Sleep(10 * time.Millisecond)
+	if got := app.ModuleStatuses()[0].Uptime; got != uptime {
+		t.Errorf("uptime after stop: got(%v) != want(%v)", got, uptime)
+	}
+
+	data, err := json.Marshal(statuses[2])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var x map[string]interface{}
+	json.Unmarshal(data, &x)
+	if x["State"] != "failed" || x["Runner"] != true {
+		t.Errorf("json: %s", data)
+	}
+}
+
+func TestModuleStatusInitFailure(t *testing.T) {
+	var events []string
+	app := New(AppOptions{})
+	a := &appModule{events: &events}
+	a.name = "a"
+	b := &appModule{events: &events, initErr: errors.New("bad init")}
+	b.name, b.depends = "b", []string{"a"}
+	app.Register(a, nil, nil)
+	app.Register(b, nil, nil)
+
+	if err := app.Start(context.Background()); err == nil {
+		t.Fatalf("start expect error but not")
+	}
+	statuses := waitStates(t, app, map[string]ModuleState{"a": Stopped, "b": Failed})
+	if got, want := statuses[1].LastError, "bad init"; got != want {
+		t.Errorf("b: last error: got(%q) != want(%q)", got, want)
+	}
+	if !statuses[1].InitTime.IsZero() || statuses[1].Uptime != 0 {
+		t.Errorf("b: init time(%v), uptime(%v)", statuses[1].InitTime, statuses[1].Uptime)
+	}
+}
@@ -0,0 +1,103 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ironzhang/matrix/framework/pkg/model"
+	"github.com/ironzhang/matrix/jsoncfg"
+	"github.com/ironzhang/matrix/tlog"
+)
+
+type unknownKey struct {
+	File   string
+	Path   string
+	Line   int
+	Module bool
+}
+
+func (k unknownKey) String() string {
+	if k.Module {
+		return fmt.Sprintf("unknown module %q", k.Path)
+	}
+	return fmt.Sprintf("unknown field %q", k.Path)
+}
+
+// unknownKeys returns the keys in the config files which match no registered module or config field.
+func unknownKeys(configs *model.Values, read readFunc, file string) ([]unknownKey, error) {
+	layers, err := readLayers(read, configFiles(file))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []unknownKey
+	for _, l := range layers {
+		lines, err := jsoncfg.KeyLines(l.format, l.data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", l.file, err)
+		}
+		var lkeys []unknownKey
+		for name, section := range l.tree {
+			cfg, ok := configs.GetInterface(name)
+			if !ok {
+				lkeys = append(lkeys, unknownKey{File: l.file, Path: name, Line: lines[name], Module: true})
+				continue
+			}
+			data, err := json.Marshal(section)
+			if err != nil {
+				return nil, err
+			}
+			paths, err := jsoncfg.UnknownFields(data, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("check %s module config: %v", name, err)
+			}
+			for _, p := range paths {
+				path := name + "." + p
+				lkeys = append(lkeys, unknownKey{File: l.file, Path: path, Line: lines[path]})
+			}
+		}
+		sort.Slice(lkeys, func(i, j int) bool {
+			if lkeys[i].Line != lkeys[j].Line {
+				return lkeys[i].Line < lkeys[j].Line
+			}
+			return lkeys[i].Path < lkeys[j].Path
+		})
+		keys = append(keys, lkeys...)
+	}
+	return keys, nil
+}
+
+// checkAppConfig fails on the unknown keys in the config file in strict mode,
+// otherwise it reports them as warnings.
+func (f *framework) checkAppConfig() error {
+	file := f.options.ConfigFile
+	if file == "" {
+		return nil
+	}
+	keys, err := unknownKeys(&f.configs, f.readFile, file)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if f.options.StrictConfig {
+		reports := make([]string, 0, len(keys))
+		for _, k := range keys {
+			reports = append(reports, fmt.Sprintf("%s:%d: %s", k.File, k.Line, k))
+		}
+		return fmt.Errorf("unknown keys:\n\t%s", strings.Join(reports, "\n\t"))
+	}
+	log := tlog.Std().Sugar()
+	for _, k := range keys {
+		if k.Module {
+			log.Warnw("unknown module", "module", k.Path, "file", k.File, "line", k.Line)
+		} else {
+			log.Warnw("unknown field", "field", k.Path, "file", k.File, "line", k.Line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package framework
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckAppConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file string
+		data string
+		keys []unknownKey
+	}{
+		{
+			file: "ok.json",
+			data: `{"a": {"Addr": ":6060", "Verbose": 1}}`,
+			keys: nil,
+		},
+		{
+			file: "bad.json",
+			data: `{
+	"a": {"Addr": ":6060", "Verbos": 1},
+	"backend-modul": {}
+}`,
+			keys: []unknownKey{
+				{File: "bad.json", Path: "a.Verbos", Line: 2},
+				{File: "bad.json", Path: "backend-modul", Line: 3, Module: true},
+			},
+		},
+		{
+			file: "bad.yaml",
+			data: "a:\n  Addr: \":6060\"\n  Verbos: 1\nbackend-modul: {}\n",
+			keys: []unknownKey{
+				{File: "bad.yaml", Path: "a.Verbos", Line: 3},
+				{File: "bad.yaml", Path: "backend-modul", Line: 4, Module: true},
+			},
+		},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(dir, tt.file)
+		if err = ioutil.WriteFile(file, []byte(tt.data), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		var f framework
+		f.configs.Register("a", &testConfig{})
+		f.options.ConfigFile = file
+		keys, err := unknownKeys(&f.configs, nil, file)
+		if err != nil {
+			t.Errorf("tests[%d]: unknown keys: %v", i, err)
+			continue
+		}
+		for j := range tt.keys {
+			tt.keys[j].File = filepath.Join(dir, tt.keys[j].File)
+		}
+		if got, want := keys, tt.keys; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: unknown keys: got(%v) != want(%v)", i, got, want)
+		}
+
+		if err = f.checkAppConfig(); err != nil {
+			t.Errorf("tests[%d]: lenient check app config: %v", i, err)
+		}
+		f.options.StrictConfig = true
+		err = f.checkAppConfig()
+		if len(tt.keys) == 0 {
+			if err != nil {
+				t.Errorf("tests[%d]: strict check app config: %v", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("tests[%d]: strict check app config expect error but not", i)
+			continue
+		}
+		for _, k := range tt.keys {
+			if !strings.Contains(err.Error(), fmt.Sprintf("%s:%d: %s", k.File, k.Line, k)) {
+				t.Errorf("tests[%d]: error %q does not contain %q", i, err, k)
+			}
+		}
+	}
+}
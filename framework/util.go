@@ -14,35 +14,58 @@ import (
 type byteSlice []byte
 
 func (bs *byteSlice) UnmarshalJSON(b []byte) error {
-	*bs = b
+	*bs = append((*bs)[0:0], b...)
 	return nil
 }
 
-func loadAppConfig(configs *model.Values, file string) (err error) {
+func loadAppConfig(configs *model.Values, read readFunc, getenv func(string) (string, bool), file string) (m map[string]byteSlice, err error) {
 	if file == "" {
-		return nil
+		return nil, nil
 	}
-	var m map[string]byteSlice
-	if err = jsoncfg.LoadFromFile(file, &m); err != nil {
-		return err
+	if m, err = loadSections(read, getenv, file); err != nil {
+		return nil, err
 	}
 	for k, v := range m {
 		if cfg, ok := configs.GetInterface(k); ok {
 			if err = json.Unmarshal(v, cfg); err != nil {
-				return fmt.Errorf("load %s module config: %v", k, err)
+				return nil, fmt.Errorf("load %s module config: %v", k, err)
 			}
 		}
 	}
-	return nil
+	return m, nil
 }
 
-func loadLogConfig(file string) (*zap.Logger, error) {
+func readLogConfig(read readFunc, file string) (cfg tlog.Config, err error) {
+	data, err := read.ReadFile(file)
+	if err != nil {
+		return cfg, err
+	}
+	err = jsoncfg.Unmarshal(jsoncfg.FormatOf(file), data, &cfg)
+	return cfg, err
+}
+
+func loadLogConfig(read readFunc, file string) (*zap.Logger, error) {
 	if file == "" {
 		return tlog.Std(), nil
 	}
-	var cfg tlog.Config
-	if err := jsoncfg.LoadFromFile(file, &cfg); err != nil {
+	cfg, err := readLogConfig(read, file)
+	if err != nil {
 		return nil, err
 	}
 	return tlog.Init(cfg)
 }
+
+// checkLogConfig builds the logger of the log config file, which is discarded
+// instead of replacing the std logger.
+func checkLogConfig(read readFunc, file string) error {
+	cfg, err := readLogConfig(read, file)
+	if err != nil {
+		return err
+	}
+	log, _, err := tlog.New(cfg)
+	if err != nil {
+		return err
+	}
+	log.Sync()
+	return nil
+}
@@ -0,0 +1,302 @@
+package jsoncfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalAnnotated is like Marshal, but in JSONC and YAML every field is commented
+// with its usage tag, whether it is readonly or writeable, and its default value.
+// The secret fields are redacted in every format.
+func MarshalAnnotated(format string, v interface{}) ([]byte, error) {
+	if format != JSON && format != JSONC && format != YAML {
+		x, err := Redact(v)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(format, x)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	annotate(root, reflect.ValueOf(v), true)
+
+	var buf bytes.Buffer
+	if format == JSON || format == JSONC {
+		if format == JSON {
+			clearComments(root)
+		}
+		writeJSONC(&buf, root, 0)
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+	clearStyle(root)
+	yamlComments(root)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err = enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteAnnotatedToFile is like WriteToFile, but writes annotated JSONC and YAML.
+func WriteAnnotatedToFile(filename string, cfg interface{}) error {
+	data, err := MarshalAnnotated(FormatOf(filename), cfg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0666)
+}
+
+type fieldInfo struct {
+	value     reflect.Value
+	usage     string
+	writeable bool
+	secret    bool
+}
+
+// jsonFields returns the fields of struct v keyed by their JSON names.
+func jsonFields(v reflect.Value, fields map[string]fieldInfo) {
+	var embedded []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous { // unexported
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = fieldInfo{
+			value:     fv,
+			usage:     sf.Tag.Get("usage"),
+			writeable: containsOption(opts, "writeable"),
+			secret:    containsOption(opts, "secret"),
+		}
+	}
+	for _, ev := range embedded {
+		sub := make(map[string]fieldInfo)
+		jsonFields(ev, sub)
+		for k, f := range sub {
+			if _, ok := fields[k]; !ok {
+				fields[k] = f
+			}
+		}
+	}
+}
+
+func containsOption(opts, name string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// annotate sets the head comments of the keys in n, which is the JSON representation of v,
+// and redacts the values of the secret fields.
+func annotate(n *yaml.Node, v reflect.Value, writeable bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		switch v.Kind() {
+		case reflect.Struct:
+			fields := make(map[string]fieldInfo)
+			jsonFields(v, fields)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, e := n.Content[i], n.Content[i+1]
+				f, ok := fields[k.Value]
+				if !ok {
+					continue
+				}
+				w := writeable && f.writeable
+				if f.secret && !(e.Kind == yaml.ScalarNode && (e.Tag == "!!null" || e.Value == "")) {
+					*e = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: Redacted}
+				}
+				k.HeadComment = comment(f.usage, w, e)
+				annotate(e, f.value, w)
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := reflect.ValueOf(n.Content[i].Value).Convert(v.Type().Key())
+				if e := v.MapIndex(key); e.IsValid() {
+					annotate(n.Content[i+1], e, writeable)
+				}
+			}
+		}
+
+	case yaml.SequenceNode:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return
+		}
+		for i, e := range n.Content {
+			if i < v.Len() {
+				annotate(e, v.Index(i), writeable)
+			}
+		}
+	}
+}
+
+func comment(usage string, writeable bool, n *yaml.Node) string {
+	attrs := []string{"readonly"}
+	if writeable {
+		attrs[0] = "writeable"
+	}
+	if n.Kind == yaml.ScalarNode {
+		attrs = append(attrs, "default: "+scalarJSON(n))
+	}
+	s := "(" + strings.Join(attrs, ", ") + ")"
+	if usage != "" {
+		s = usage + " " + s
+	}
+	return s
+}
+
+func scalarJSON(n *yaml.Node) string {
+	if n.Tag == "!!str" {
+		data, _ := json.Marshal(n.Value)
+		return string(data)
+	}
+	return n.Value
+}
+
+func clearComments(n *yaml.Node) {
+	n.HeadComment = ""
+	for _, c := range n.Content {
+		clearComments(c)
+	}
+}
+
+func yamlComments(n *yaml.Node) {
+	if n.HeadComment != "" {
+		n.HeadComment = "# " + n.HeadComment
+	}
+	for _, c := range n.Content {
+		yamlComments(c)
+	}
+}
+
+func writeJSONC(b *bytes.Buffer, n *yaml.Node, depth int) {
+	indent := strings.Repeat("\t", depth)
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{\n")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, e := n.Content[i], n.Content[i+1]
+			if k.HeadComment != "" {
+				b.WriteString(indent + "\t// " + k.HeadComment + "\n")
+			}
+			key, _ := json.Marshal(k.Value)
+			b.WriteString(indent + "\t")
+			b.Write(key)
+			b.WriteString(": ")
+			writeJSONC(b, e, depth+1)
+			if i+2 < len(n.Content) {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[\n")
+		for i, e := range n.Content {
+			b.WriteString(indent + "\t")
+			writeJSONC(b, e, depth+1)
+			if i+1 < len(n.Content) {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "]")
+
+	default:
+		b.WriteString(scalarJSON(n))
+	}
+}
+
+// stripComments replaces the comments in JSONC data with spaces, so the offsets
+// and line numbers are kept.
+func stripComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+		}
+	}
+	return out
+}
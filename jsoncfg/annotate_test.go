@@ -0,0 +1,120 @@
+package jsoncfg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAnnotated struct {
+	Addr    string   `json:",readonly" usage:"listen address"`
+	Timeout Duration `json:",writeable" usage:"request timeout"`
+	Hosts   []string `json:"hosts"`
+	Sub     testSub  `json:",writeable"`
+	Any     interface{}
+}
+
+func TestMarshalAnnotated(t *testing.T) {
+	v := map[string]interface{}{
+		"a": &testAnnotated{
+			Addr:    ":6060",
+			Timeout: Duration(time.Second),
+			Hosts:   []string{"h"},
+			Sub:     testSub{Name: "s"},
+		},
+	}
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{
+			format: JSONC,
+			data: `{
+	"a": {
+		// listen address (readonly, default: ":6060")
+		"Addr": ":6060",
+		// request timeout (writeable, default: "1s")
+		"Timeout": "1s",
+		// (readonly)
+		"hosts": [
+			"h"
+		],
+		// (writeable)
+		"Sub": {
+			// (readonly, default: "s")
+			"Name": "s",
+			// (readonly, default: 0)
+			"Ratio": 0
+		},
+		// (readonly, default: null)
+		"Any": null
+	}
+}
+`,
+		},
+		{
+			format: YAML,
+			data: `a:
+  # listen address (readonly, default: ":6060")
+  Addr: :6060
+  # request timeout (writeable, default: "1s")
+  Timeout: 1s
+  # (readonly)
+  hosts:
+    - h
+  # (writeable)
+  Sub:
+    # (readonly, default: "s")
+    Name: s
+    # (readonly, default: 0)
+    Ratio: 0
+  # (readonly, default: null)
+  Any: null
+`,
+		},
+	}
+	for i, tt := range tests {
+		data, err := MarshalAnnotated(tt.format, v)
+		if err != nil {
+			t.Errorf("tests[%d]: %s: marshal annotated: %v", i, tt.format, err)
+			continue
+		}
+		if got, want := string(data), tt.data; got != want {
+			t.Errorf("tests[%d]: %s: got:\n%s\nwant:\n%s", i, tt.format, got, want)
+		}
+
+		var got map[string]testAnnotated
+		if err = Unmarshal(tt.format, data, &got); err != nil {
+			t.Errorf("tests[%d]: %s: unmarshal: %v", i, tt.format, err)
+			continue
+		}
+		if want := map[string]testAnnotated{"a": *v["a"].(*testAnnotated)}; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: %s: got(%v) != want(%v)", i, tt.format, got, want)
+		}
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "{\"a\": 1} // c",
+			out: "{\"a\": 1}     ",
+		},
+		{
+			in:  "{\"a//b\": \"/*\\\"*/\"}",
+			out: "{\"a//b\": \"/*\\\"*/\"}",
+		},
+		{
+			in:  "{/* a\nb */\"a\": 1}",
+			out: "{    \n    \"a\": 1}",
+		},
+	}
+	for i, tt := range tests {
+		if got, want := string(stripComments([]byte(tt.in))), tt.out; got != want {
+			t.Errorf("tests[%d]: got(%q) != want(%q)", i, got, want)
+		}
+	}
+}
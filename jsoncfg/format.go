@@ -0,0 +1,149 @@
+package jsoncfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JSON  = "json"
+	JSONC = "jsonc"
+	YAML  = "yaml"
+	TOML  = "toml"
+)
+
+// FormatOf returns the format of the file by its extension, JSON by default.
+func FormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonc":
+		return JSONC
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	default:
+		return JSON
+	}
+}
+
+// Marshal encodes v in the format. v is encoded to JSON first, so the json tags,
+// json.Marshaler and encoding.TextMarshaler work in every format.
+func Marshal(format string, v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case JSON, JSONC:
+		return data, nil
+	case YAML:
+		var node yaml.Node
+		if err = yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		clearStyle(&node)
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		if err = enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case TOML:
+		x, err := decodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err = toml.NewEncoder(&buf).Encode(x); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// Unmarshal decodes data in the format to v. The data is converted to JSON first,
+// so the json tags, json.Unmarshaler and encoding.TextUnmarshaler work in every format.
+func Unmarshal(format string, data []byte, v interface{}) error {
+	data, err := ToJSON(format, data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ToJSON converts data in the format to JSON.
+func ToJSON(format string, data []byte) ([]byte, error) {
+	var x interface{}
+	switch format {
+	case JSON:
+		return data, nil
+	case JSONC:
+		return stripComments(data), nil
+	case YAML:
+		if err := yaml.Unmarshal(data, &x); err != nil {
+			return nil, err
+		}
+		if x == nil {
+			x = map[string]interface{}{}
+		}
+	case TOML:
+		m := make(map[string]interface{})
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		x = m
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	return json.Marshal(x)
+}
+
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
+
+// decodeJSON decodes data to generic values, the integers are kept as int64.
+func decodeJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var x interface{}
+	if err := d.Decode(&x); err != nil {
+		return nil, err
+	}
+	return convertNumber(x), nil
+}
+
+func convertNumber(x interface{}) interface{} {
+	switch v := x.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumber(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumber(e)
+		}
+	}
+	return x
+}
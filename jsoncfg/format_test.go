@@ -0,0 +1,121 @@
+package jsoncfg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testSub struct {
+	Name  string
+	Ratio float64
+}
+
+type testConfig struct {
+	Addr    string   `json:"addr,readonly"`
+	Verbose int      `json:",writeable"`
+	Timeout Duration `json:"timeout"`
+	Hosts   []string `json:"hosts"`
+	Sub     testSub  `json:"sub"`
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		filename string
+		format   string
+	}{
+		{filename: "a.json", format: JSON},
+		{filename: "a.jsonc", format: JSONC},
+		{filename: "a.yaml", format: YAML},
+		{filename: "a.YML", format: YAML},
+		{filename: "a.toml", format: TOML},
+		{filename: "a", format: JSON},
+	}
+	for i, tt := range tests {
+		if got, want := FormatOf(tt.filename), tt.format; got != want {
+			t.Errorf("tests[%d]: %s: got(%s) != want(%s)", i, tt.filename, got, want)
+		}
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	want := map[string]testConfig{
+		"a": {
+			Addr:    ":6060",
+			Verbose: 2,
+			Timeout: Duration(3 * time.Second),
+			Hosts:   []string{"a", "b"},
+			Sub:     testSub{Name: "sub", Ratio: 0.5},
+		},
+	}
+	for _, format := range []string{JSON, JSONC, YAML, TOML} {
+		data, err := Marshal(format, want)
+		if err != nil {
+			t.Errorf("%s: marshal: %v", format, err)
+			continue
+		}
+		var got map[string]testConfig
+		if err = Unmarshal(format, data, &got); err != nil {
+			t.Errorf("%s: unmarshal: %v\n%s", format, err, data)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got(%v) != want(%v)\n%s", format, got, want, data)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	want := testConfig{
+		Addr:    ":6060",
+		Verbose: 2,
+		Timeout: Duration(time.Minute),
+		Hosts:   []string{"a"},
+		Sub:     testSub{Name: "sub", Ratio: 1},
+	}
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{
+			format: JSON,
+			data:   `{"addr": ":6060", "Verbose": 2, "timeout": "1m", "hosts": ["a"], "sub": {"Name": "sub", "Ratio": 1}}`,
+		},
+		{
+			format: YAML,
+			data: `
+addr: ":6060"
+Verbose: 2
+timeout: 1m
+hosts:
+  - a
+sub:
+  Name: sub
+  Ratio: 1
+`,
+		},
+		{
+			format: TOML,
+			data: `
+addr = ":6060"
+Verbose = 2
+timeout = "1m"
+hosts = ["a"]
+
+[sub]
+Name = "sub"
+Ratio = 1.0
+`,
+		},
+	}
+	for i, tt := range tests {
+		var got testConfig
+		if err := Unmarshal(tt.format, []byte(tt.data), &got); err != nil {
+			t.Errorf("tests[%d]: %s: unmarshal: %v", i, tt.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: %s: got(%v) != want(%v)", i, tt.format, got, want)
+		}
+	}
+}
@@ -1,23 +1,24 @@
 package jsoncfg
 
 import (
-	"encoding/json"
 	"io/ioutil"
 )
 
+// LoadFromFile loads cfg from the file, the format is picked by the file extension.
 func LoadFromFile(filename string, cfg interface{}) error {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	if err = json.Unmarshal(data, cfg); err != nil {
+	if err = Unmarshal(FormatOf(filename), data, cfg); err != nil {
 		return err
 	}
 	return nil
 }
 
+// WriteToFile writes cfg to the file, the format is picked by the file extension.
 func WriteToFile(filename string, cfg interface{}) error {
-	data, err := json.MarshalIndent(cfg, "", "\t")
+	data, err := Marshal(FormatOf(filename), cfg)
 	if err != nil {
 		return err
 	}
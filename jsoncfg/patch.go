@@ -0,0 +1,216 @@
+package jsoncfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Patch sets the values keyed by the dotted paths in data of the format, the rest of
+// data, such as the comments and the order of the keys, is kept. The missing keys are
+// appended to their objects. TOML is not supported.
+func Patch(format string, data []byte, values map[string]interface{}) ([]byte, error) {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var err error
+	switch format {
+	case JSON, JSONC:
+		for _, path := range paths {
+			if data, err = patchJSON(data, strings.Split(path, "."), values[path]); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		return data, nil
+	case YAML:
+		return patchYAML(data, paths, values)
+	default:
+		return nil, fmt.Errorf("patch %s not supported", format)
+	}
+}
+
+type jsonSpan struct {
+	start, end int64
+}
+
+// jsonSpans records the spans of the object members in data, keyed by their paths,
+// the comments of which have been replaced with spaces.
+func jsonSpans(spans map[string]jsonSpan, d *json.Decoder, data []byte, path string, start int64, record bool) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for d.More() {
+			if tok, err = d.Token(); err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			if err = jsonSpans(spans, d, data, joinPath(path, key), skipSpaces(data, d.InputOffset(), ":"), record); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	case json.Delim('['):
+		for d.More() {
+			if err = jsonSpans(spans, d, data, path, 0, false); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	}
+	if record {
+		spans[path] = jsonSpan{start: start, end: d.InputOffset()}
+	}
+	return err
+}
+
+// skipSpaces returns the offset of the first byte from i which is neither a space nor in chars.
+func skipSpaces(data []byte, i int64, chars string) int64 {
+	for ; i < int64(len(data)); i++ {
+		c := data[i]
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && strings.IndexByte(chars, c) < 0 {
+			break
+		}
+	}
+	return i
+}
+
+// lineIndent returns the leading spaces of the line at offset i.
+func lineIndent(data []byte, i int64) string {
+	begin := bytes.LastIndexByte(data[:i], '\n') + 1
+	end := begin
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[begin:end])
+}
+
+// nest returns value nested in the objects of keys.
+func nest(keys []string, value interface{}) interface{} {
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = map[string]interface{}{keys[i]: value}
+	}
+	return value
+}
+
+func patchJSON(data []byte, keys []string, value interface{}) ([]byte, error) {
+	scan := stripComments(data)
+	spans := make(map[string]jsonSpan)
+	root := skipSpaces(scan, 0, "")
+	if root == int64(len(scan)) {
+		data, scan, root = []byte("{}"), []byte("{}"), 0
+	}
+	if scan[root] != '{' {
+		return nil, fmt.Errorf("not an object")
+	}
+	d := json.NewDecoder(bytes.NewReader(scan))
+	if err := jsonSpans(spans, d, scan, "", root, true); err != nil {
+		return nil, err
+	}
+
+	// the longest path of keys in data
+	i := len(keys)
+	for ; i > 0; i-- {
+		if _, ok := spans[strings.Join(keys[:i], ".")]; ok {
+			break
+		}
+	}
+	span := spans[strings.Join(keys[:i], ".")]
+	indent := lineIndent(data, span.start)
+	if i == len(keys) || scan[span.start] != '{' {
+		v, err := json.MarshalIndent(nest(keys[i:], value), indent, "\t")
+		if err != nil {
+			return nil, err
+		}
+		return splice(data, span.start, span.end, v), nil
+	}
+
+	// append the member to the object
+	v, err := json.MarshalIndent(nest(keys[i+1:], value), indent+"\t", "\t")
+	if err != nil {
+		return nil, err
+	}
+	key, _ := json.Marshal(keys[i])
+	member := "\n" + indent + "\t" + string(key) + ": " + string(v)
+	last := span.end - 1
+	for last > span.start && strings.IndexByte(" \t\r\n", scan[last-1]) >= 0 {
+		last--
+	}
+	if last-1 == span.start {
+		return splice(data, span.start+1, span.end-1, []byte(member+"\n"+indent)), nil
+	}
+	return splice(data, last, last, []byte(","+member)), nil
+}
+
+func splice(data []byte, start, end int64, v []byte) []byte {
+	out := make([]byte, 0, len(data)-int(end-start)+len(v))
+	out = append(out, data[:start]...)
+	out = append(out, v...)
+	return append(out, data[end:]...)
+}
+
+func patchYAML(data []byte, paths []string, values map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a mapping")
+	}
+	for _, path := range paths {
+		if err := setYAML(doc.Content[0], strings.Split(path, "."), values[path]); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setYAML sets value at keys in the mapping n, the comments of the replaced node are kept.
+func setYAML(n *yaml.Node, keys []string, value interface{}) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != keys[0] {
+			continue
+		}
+		e := n.Content[i+1]
+		if len(keys) > 1 && e.Kind == yaml.MappingNode {
+			return setYAML(e, keys[1:], value)
+		}
+		var v yaml.Node
+		if err := v.Encode(nest(keys[1:], value)); err != nil {
+			return err
+		}
+		v.HeadComment, v.LineComment, v.FootComment = e.HeadComment, e.LineComment, e.FootComment
+		n.Content[i+1] = &v
+		return nil
+	}
+
+	var k, v yaml.Node
+	k.SetString(keys[0])
+	if err := v.Encode(nest(keys[1:], value)); err != nil {
+		return err
+	}
+	n.Content = append(n.Content, &k, &v)
+	return nil
+}
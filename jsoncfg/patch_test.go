@@ -0,0 +1,74 @@
+package jsoncfg
+
+import (
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		values map[string]interface{}
+		want   string
+		err    bool
+	}{
+		{
+			format: JSON,
+			data:   "{\n\t\"b\": {\n\t\t\"y\": 1,\n\t\t\"x\": \"s\"\n\t},\n\t\"a\": 1\n}\n",
+			values: map[string]interface{}{"b.x": "t", "a": []interface{}{1, 2}},
+			want:   "{\n\t\"b\": {\n\t\t\"y\": 1,\n\t\t\"x\": \"t\"\n\t},\n\t\"a\": [\n\t\t1,\n\t\t2\n\t]\n}\n",
+		},
+		{
+			format: JSON,
+			data:   "{\n\t\"b\": {\n\t\t\"y\": 1\n\t},\n\t\"c\": {}\n}",
+			values: map[string]interface{}{"b.z": 2, "c.x": 3, "d.e.f": true},
+			want:   "{\n\t\"b\": {\n\t\t\"y\": 1,\n\t\t\"z\": 2\n\t},\n\t\"c\": {\n\t\t\"x\": 3\n\t},\n\t\"d\": {\n\t\t\"e\": {\n\t\t\t\"f\": true\n\t\t}\n\t}\n}",
+		},
+		{
+			format: JSON,
+			data:   `{"a": "${file:a.txt}"}`,
+			values: map[string]interface{}{"a.b": 1},
+			want:   "{\"a\": {\n\t\"b\": 1\n}}",
+		},
+		{
+			format: JSON,
+			data:   "",
+			values: map[string]interface{}{"a": 1},
+			want:   "{\n\t\"a\": 1\n}",
+		},
+		{
+			format: JSONC,
+			data:   "{\n\t// the address, \"a\": 2\n\t\"a\": 1, /* one */\n\t\"b\": \"//\" // last\n}\n",
+			values: map[string]interface{}{"a": 2, "c": 3},
+			want:   "{\n\t// the address, \"a\": 2\n\t\"a\": 2, /* one */\n\t\"b\": \"//\",\n\t\"c\": 3 // last\n}\n",
+		},
+		{
+			format: YAML,
+			data:   "# head\nb:\n  y: 1 # line\n  x: s\na: 1\n",
+			values: map[string]interface{}{"b.y": 2, "c.d": "e"},
+			want:   "# head\nb:\n  y: 2 # line\n  x: s\na: 1\nc:\n  d: e\n",
+		},
+		{
+			format: JSON,
+			data:   "[]",
+			values: map[string]interface{}{"a": 1},
+			err:    true,
+		},
+		{
+			format: TOML,
+			data:   "a = 1\n",
+			values: map[string]interface{}{"a": 2},
+			err:    true,
+		},
+	}
+	for i, tt := range tests {
+		data, err := Patch(tt.format, []byte(tt.data), tt.values)
+		if (err != nil) != tt.err {
+			t.Errorf("tests[%d]: error: %v", i, err)
+			continue
+		}
+		if err == nil && string(data) != tt.want {
+			t.Errorf("tests[%d]: got:\n%s\nwant:\n%s", i, data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package jsoncfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values of the fields tagged with the secret option, e.g. `json:",secret"`.
+const Redacted = "******"
+
+// Redact returns the JSON representation of v, in which the non-empty values of
+// the secret fields are replaced with Redacted.
+func Redact(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var x interface{}
+	if err = json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	RedactTree(x, v)
+	return x, nil
+}
+
+// RedactTree replaces the non-empty values of the secret fields in tree, which is
+// a JSON representation of a value of the same type as v.
+func RedactTree(tree interface{}, v interface{}) {
+	redact(tree, reflect.ValueOf(v))
+}
+
+func redact(tree interface{}, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch x := tree.(type) {
+	case map[string]interface{}:
+		switch v.Kind() {
+		case reflect.Struct:
+			fields := make(map[string]fieldInfo)
+			jsonFields(v, fields)
+			for k, e := range x {
+				f, ok := fields[k]
+				if !ok {
+					continue
+				}
+				if f.secret {
+					if e != nil && e != "" {
+						x[k] = Redacted
+					}
+					continue
+				}
+				redact(e, f.value)
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return
+			}
+			for k, e := range x {
+				if ev := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key())); ev.IsValid() {
+					redact(e, ev)
+				}
+			}
+		}
+
+	case []interface{}:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return
+		}
+		for i, e := range x {
+			if i < v.Len() {
+				redact(e, v.Index(i))
+			}
+		}
+	}
+}
+
+// DropRedacted deletes the secret fields whose values are Redacted from tree, which is
+// a JSON representation of a value of the same type as v, so the redacted values read
+// back are not taken as the new values.
+func DropRedacted(tree interface{}, v interface{}) {
+	dropRedacted(tree, reflect.ValueOf(v))
+}
+
+func dropRedacted(tree interface{}, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	x, ok := tree.(map[string]interface{})
+	if !ok || v.Kind() != reflect.Struct {
+		return
+	}
+	fields := make(map[string]fieldInfo)
+	jsonFields(v, fields)
+	for k, e := range x {
+		f, ok := fields[k]
+		if !ok {
+			continue
+		}
+		if f.secret && e == Redacted {
+			delete(x, k)
+			continue
+		}
+		dropRedacted(e, f.value)
+	}
+}
+
+var refPattern = regexp.MustCompile(`\$\{(env|file):([^}]*)\}`)
+
+// IsRef reports whether s contains secret references.
+func IsRef(s string) bool {
+	return refPattern.MatchString(s)
+}
+
+// Resolver resolves the secret references with LookupEnv and ReadFile,
+// which are os.LookupEnv and ioutil.ReadFile if nil.
+type Resolver struct {
+	LookupEnv func(name string) (string, bool)
+	ReadFile  func(filename string) ([]byte, error)
+}
+
+// ResolveRefs replaces the secret references in s, ${env:NAME} with the value of
+// the environment variable and ${file:path} with the content of the file,
+// the trailing newlines of which are trimmed.
+func (r Resolver) ResolveRefs(s string) (string, error) {
+	lookupEnv, readFile := r.LookupEnv, r.ReadFile
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+
+	var err error
+	out := refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := refPattern.FindStringSubmatch(ref)
+		switch m[1] {
+		case "env":
+			v, ok := lookupEnv(m[2])
+			if !ok {
+				err = fmt.Errorf("resolve %s: env not set", ref)
+			}
+			return v
+		default:
+			data, e := readFile(m[2])
+			if e != nil {
+				err = fmt.Errorf("resolve %s: %v", ref, e)
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// ResolveTree replaces the secret references in the string values of tree, which is a JSON representation.
+func (r Resolver) ResolveTree(tree interface{}) (interface{}, error) {
+	switch x := tree.(type) {
+	case string:
+		return r.ResolveRefs(x)
+	case map[string]interface{}:
+		for k, e := range x {
+			v, err := r.ResolveTree(e)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = v
+		}
+	case []interface{}:
+		for i, e := range x {
+			v, err := r.ResolveTree(e)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = v
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,166 @@
+package jsoncfg
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testSecret struct {
+	User     string
+	Password string            `json:",secret"`
+	Token    string            `json:"token,writeable,secret"`
+	Keys     map[string]string `json:",secret"`
+	Subs     []testSecret
+}
+
+func TestRedact(t *testing.T) {
+	v := map[string]interface{}{
+		"a": &testSecret{
+			User:     "u",
+			Password: "p",
+			Keys:     map[string]string{"k": "v"},
+			Subs:     []testSecret{{User: "s", Token: "t"}},
+		},
+	}
+	got, err := Redact(v)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	want := map[string]interface{}{
+		"a": map[string]interface{}{
+			"User":     "u",
+			"Password": Redacted,
+			"token":    "",
+			"Keys":     Redacted,
+			"Subs": []interface{}{
+				map[string]interface{}{"User": "s", "Password": "", "token": Redacted, "Keys": nil, "Subs": nil},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got(%v) != want(%v)", got, want)
+	}
+}
+
+func TestMarshalAnnotatedSecret(t *testing.T) {
+	v := map[string]interface{}{
+		"a": &testSecret{User: "u", Password: "p"},
+	}
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{
+			format: JSON,
+			data: `{
+	"a": {
+		"User": "u",
+		"Password": "******",
+		"token": "",
+		"Keys": null,
+		"Subs": null
+	}
+}
+`,
+		},
+		{
+			format: YAML,
+			data: `a:
+  # (readonly, default: "u")
+  User: u
+  # (readonly, default: "******")
+  Password: '******'
+  # (writeable, default: "")
+  token: ""
+  # (readonly, default: null)
+  Keys: null
+  # (readonly, default: null)
+  Subs: null
+`,
+		},
+	}
+	for i, tt := range tests {
+		data, err := MarshalAnnotated(tt.format, v)
+		if err != nil {
+			t.Errorf("tests[%d]: %s: marshal annotated: %v", i, tt.format, err)
+			continue
+		}
+		if got, want := string(data), tt.data; got != want {
+			t.Errorf("tests[%d]: %s: got:\n%s\nwant:\n%s", i, tt.format, got, want)
+		}
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	file, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("file-secret\n")
+	file.Close()
+	os.Setenv("JSONCFG_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("JSONCFG_TEST_SECRET")
+
+	fake := Resolver{
+		LookupEnv: func(name string) (string, bool) {
+			if name == "JSONCFG_TEST_SECRET" {
+				return "fake-env", true
+			}
+			return "", false
+		},
+		ReadFile: func(filename string) ([]byte, error) {
+			if filename == file.Name() {
+				return []byte("fake-file\r\n"), nil
+			}
+			return nil, os.ErrNotExist
+		},
+	}
+
+	tests := []struct {
+		r   Resolver
+		in  string
+		out string
+		err bool
+	}{
+		{in: "plain", out: "plain"},
+		{in: "${env:JSONCFG_TEST_SECRET}", out: "env-secret"},
+		{in: "${file:" + file.Name() + "}", out: "file-secret"},
+		{in: "u:${env:JSONCFG_TEST_SECRET}@host", out: "u:env-secret@host"},
+		{in: "${vault:x}", out: "${vault:x}"},
+		{in: "${env:JSONCFG_TEST_NOT_SET}", err: true},
+		{in: "${file:/not/exist}", err: true},
+		{r: fake, in: "${env:JSONCFG_TEST_SECRET}", out: "fake-env"},
+		{r: fake, in: "${file:" + file.Name() + "}", out: "fake-file"},
+		{r: fake, in: "${env:HOME}", err: true},
+	}
+	for i, tt := range tests {
+		out, err := tt.r.ResolveRefs(tt.in)
+		if got, want := err != nil, tt.err; got != want {
+			t.Errorf("tests[%d]: error: got(%v) != want(%v), %v", i, got, want, err)
+			continue
+		}
+		if got, want := out, tt.out; got != want {
+			t.Errorf("tests[%d]: got(%q) != want(%q)", i, got, want)
+		}
+	}
+}
+
+func TestDropRedacted(t *testing.T) {
+	v := &testSecret{User: "u", Password: "p"}
+	tree := map[string]interface{}{
+		"User":     "x",
+		"Password": Redacted,
+		"token":    "t",
+	}
+	DropRedacted(tree, v)
+	want := map[string]interface{}{
+		"User":  "x",
+		"token": "t",
+	}
+	if got := tree; !reflect.DeepEqual(got, want) {
+		t.Errorf("got(%v) != want(%v)", got, want)
+	}
+}
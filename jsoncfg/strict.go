@@ -0,0 +1,218 @@
+package jsoncfg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// UnknownFields returns the paths of the keys in JSON data which do not match any field of v.
+func UnknownFields(data []byte, v interface{}) ([]string, error) {
+	var x interface{}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	var paths []string
+	unknownFields(&paths, "", x, reflect.TypeOf(v))
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func unknownFields(paths *[]string, prefix string, x interface{}, t reflect.Type) {
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(jsonUnmarshalerType) || t.Implements(textUnmarshalerType) {
+			return
+		}
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := make(map[string]fieldInfo)
+		jsonFields(reflect.New(t).Elem(), fields)
+		for k, e := range m {
+			f, ok := lookupField(fields, k)
+			if !ok {
+				*paths = append(*paths, joinPath(prefix, k))
+				continue
+			}
+			unknownFields(paths, joinPath(prefix, k), e, f.value.Type())
+		}
+	case reflect.Map:
+		if m, ok := x.(map[string]interface{}); ok {
+			for k, e := range m {
+				unknownFields(paths, joinPath(prefix, k), e, t.Elem())
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if a, ok := x.([]interface{}); ok {
+			for i, e := range a {
+				unknownFields(paths, fmt.Sprintf("%s[%d]", prefix, i), e, t.Elem())
+			}
+		}
+	}
+}
+
+// lookupField looks up the field like encoding/json, preferring an exact match
+// but also accepting a case-insensitive match.
+func lookupField(fields map[string]fieldInfo, key string) (fieldInfo, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// KeyLines returns the line numbers of the keys in data, keyed by their paths
+// as returned by UnknownFields.
+func KeyLines(format string, data []byte) (map[string]int, error) {
+	lines := make(map[string]int)
+	switch format {
+	case JSON, JSONC:
+		if format == JSONC {
+			data = stripComments(data)
+		}
+		d := json.NewDecoder(bytes.NewReader(data))
+		if err := jsonKeyLines(lines, d, data, ""); err != nil {
+			return nil, err
+		}
+	case YAML:
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			yamlKeyLines(lines, doc.Content[0], "")
+		}
+	case TOML:
+		tomlKeyLines(lines, data)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	return lines, nil
+}
+
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func jsonKeyLines(lines map[string]int, d *json.Decoder, data []byte, prefix string) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for d.More() {
+			tok, err = d.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			path := joinPath(prefix, key)
+			lines[path] = lineAt(data, d.InputOffset())
+			if err = jsonKeyLines(lines, d, data, path); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	case json.Delim('['):
+		for i := 0; d.More(); i++ {
+			if err = jsonKeyLines(lines, d, data, fmt.Sprintf("%s[%d]", prefix, i)); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+	}
+	return err
+}
+
+func yamlKeyLines(lines map[string]int, n *yaml.Node, prefix string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			path := joinPath(prefix, n.Content[i].Value)
+			lines[path] = n.Content[i].Line
+			yamlKeyLines(lines, n.Content[i+1], path)
+		}
+	case yaml.SequenceNode:
+		for i, e := range n.Content {
+			yamlKeyLines(lines, e, fmt.Sprintf("%s[%d]", prefix, i))
+		}
+	}
+}
+
+// tomlKeyLines finds the lines of the table headers and the keys in TOML data,
+// it doesn't handle inline tables and multi-line strings.
+func tomlKeyLines(lines map[string]int, data []byte) {
+	var table string
+	tables := make(map[string]int)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || line[0] == '#':
+		case strings.HasPrefix(line, "[["):
+			table = tomlKey(strings.Trim(line, "[] "))
+			i := tables[table]
+			tables[table] = i + 1
+			lines[table] = n
+			table = fmt.Sprintf("%s[%d]", table, i)
+		case line[0] == '[':
+			table = tomlKey(strings.Trim(line, "[] "))
+			lines[table] = n
+		default:
+			if i := strings.Index(line, "="); i > 0 {
+				lines[joinPath(table, tomlKey(line[:i]))] = n
+			}
+		}
+	}
+}
+
+func tomlKey(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if u, err := strconv.Unquote(p); err == nil {
+			p = u
+		} else {
+			p = strings.Trim(p, "'")
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,91 @@
+package jsoncfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	type S struct {
+		Name string
+	}
+	type T struct {
+		Addr    string   `json:"addr"`
+		Timeout Duration `json:"timeout"`
+		Subs    []S
+		M       map[string]S
+		Any     interface{}
+	}
+
+	data := `{
+		"addr": ":6060",
+		"Addr": ":6061",
+		"adr": ":6062",
+		"timeout": "1s",
+		"Subs": [{"Name": "a"}, {"Nmae": "b"}],
+		"M": {"x": {"name": "x", "y": 1}},
+		"Any": {"z": 1}
+	}`
+	paths, err := UnknownFields([]byte(data), &T{})
+	if err != nil {
+		t.Fatalf("unknown fields: %v", err)
+	}
+	if got, want := paths, []string{"M.x.y", "Subs[1].Nmae", "adr"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paths: got(%v) != want(%v)", got, want)
+	}
+}
+
+func TestKeyLines(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		lines  map[string]int
+	}{
+		{
+			format: JSONC,
+			data: `{
+	// comment
+	"a": {
+		"b": 1,
+		"c": [{"d": 2}]
+	}
+}`,
+			lines: map[string]int{"a": 3, "a.b": 4, "a.c": 5, "a.c[0].d": 5},
+		},
+		{
+			format: YAML,
+			data: `
+# comment
+a:
+  b: 1
+  c:
+    - d: 2
+`,
+			lines: map[string]int{"a": 3, "a.b": 4, "a.c": 5, "a.c[0].d": 6},
+		},
+		{
+			format: TOML,
+			data: `
+# comment
+[a]
+b = 1
+
+[[a.c]]
+d = 2
+`,
+			lines: map[string]int{"a": 3, "a.b": 4, "a.c": 6, "a.c[0].d": 7},
+		},
+	}
+	for i, tt := range tests {
+		lines, err := KeyLines(tt.format, []byte(tt.data))
+		if err != nil {
+			t.Errorf("tests[%d]: %s: key lines: %v", i, tt.format, err)
+			continue
+		}
+		for path, line := range tt.lines {
+			if got := lines[path]; got != line {
+				t.Errorf("tests[%d]: %s: %s: got(%d) != want(%d)", i, tt.format, path, got, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets, in seconds for the latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// vec holds the series of a metric, one for every combination of the label values.
+type vec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	create  func() interface{}
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  interface{}
+}
+
+func newVec(name, help, typ string, labels []string, create func() interface{}) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		create: create,
+		series: make(map[string]*series),
+	}
+}
+
+func (v *vec) get(values []string) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric(%s) has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.value
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; !ok {
+		s = &series{values: append([]string(nil), values...), value: v.create()}
+		v.series[key] = s
+	}
+	return s.value
+}
+
+func (v *vec) lookup(values []string) (interface{}, bool) {
+	v.mu.RLock()
+	s, ok := v.series[strings.Join(values, "\xff")]
+	v.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return s.value, true
+}
+
+func (v *vec) delete(values []string) {
+	v.mu.Lock()
+	delete(v.series, strings.Join(values, "\xff"))
+	v.mu.Unlock()
+}
+
+// sorted returns the series sorted by the label values.
+func (v *vec) sorted() []*series {
+	v.mu.RLock()
+	ss := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		ss = append(ss, s)
+	}
+	v.mu.RUnlock()
+	sort.Slice(ss, func(i, j int) bool {
+		a, b := ss[i].values, ss[j].values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return ss
+}
+
+type float struct {
+	bits uint64
+}
+
+func (f *float) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+func (f *float) store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *float) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, n) {
+			return
+		}
+	}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	vec *vec
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the series of the label values, it panics if v is negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter(%s) can not decrease", c.vec.name))
+	}
+	c.vec.get(values).(*float).add(v)
+}
+
+// Value returns the value of the series, or 0 if the series does not exist.
+func (c *Counter) Value(values ...string) float64 {
+	if x, ok := c.vec.lookup(values); ok {
+		return x.(*float).load()
+	}
+	return 0
+}
+
+func (c *Counter) Delete(values ...string) {
+	c.vec.delete(values)
+}
+
+// Gauge is a value which can go up and down.
+type Gauge struct {
+	vec *vec
+}
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.vec.get(values).(*float).store(v)
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.vec.get(values).(*float).add(v)
+}
+
+func (g *Gauge) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+func (g *Gauge) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+// Value returns the value of the series, or 0 if the series does not exist.
+func (g *Gauge) Value(values ...string) float64 {
+	if x, ok := g.vec.lookup(values); ok {
+		return x.(*float).load()
+	}
+	return 0
+}
+
+func (g *Gauge) Delete(values ...string) {
+	g.vec.delete(values)
+}
+
+// Histogram counts the observations in the buckets.
+type Histogram struct {
+	vec *vec
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	x := h.vec.get(values).(*histogram)
+	i := sort.SearchFloat64s(h.vec.buckets, v)
+	x.mu.Lock()
+	if i < len(x.counts) {
+		x.counts[i]++
+	}
+	x.sum += v
+	x.count++
+	x.mu.Unlock()
+}
+
+// Count returns the number of the observations and their sum.
+func (h *Histogram) Count(values ...string) (uint64, float64) {
+	v, ok := h.vec.lookup(values)
+	if !ok {
+		return 0, 0
+	}
+	x := v.(*histogram)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.count, x.sum
+}
+
+func (h *Histogram) Delete(values ...string) {
+	h.vec.delete(values)
+}
+
+// snapshot returns the cumulative counts of the buckets, the sum and the count.
+func (x *histogram) snapshot() ([]uint64, float64, uint64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	counts := make([]uint64, len(x.counts))
+	var n uint64
+	for i, c := range x.counts {
+		n += c
+		counts[i] = n
+	}
+	return counts, x.sum, x.count
+}
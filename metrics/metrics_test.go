@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("http_requests_total", "The number of the requests.", "method", "code")
+	inflight := r.NewGauge("http_inflight", "The requests in flight.")
+	latency := r.NewHistogram("http_latency_seconds", "The latency\nof the requests.", []float64{1, 0.1}, "method")
+	r.NewGaugeFunc("up", "Always 1.", func() float64 { return 1 })
+
+	requests.Inc("GET", "200")
+	requests.Add(2, "GET", "200")
+	requests.Inc("POST", "500")
+	requests.Inc("GET", `a"b\c`)
+	inflight.Inc()
+	inflight.Inc()
+	inflight.Dec()
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.5, "GET")
+	latency.Observe(3, "GET")
+
+	var b bytes.Buffer
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatalf("write prometheus: %v", err)
+	}
+	want := `# HELP http_inflight The requests in flight.
+# TYPE http_inflight gauge
+http_inflight 1
+# HELP http_latency_seconds The latency\nof the requests.
+# TYPE http_latency_seconds histogram
+http_latency_seconds_bucket{method="GET",le="0.1"} 1
+http_latency_seconds_bucket{method="GET",le="1"} 2
+http_latency_seconds_bucket{method="GET",le="+Inf"} 3
+http_latency_seconds_sum{method="GET"} 3.55
+http_latency_seconds_count{method="GET"} 3
+# HELP http_requests_total The number of the requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 3
+http_requests_total{method="GET",code="a\"b\\c"} 1
+http_requests_total{method="POST",code="500"} 1
+# HELP up Always 1.
+# TYPE up gauge
+up 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type: %s", ct)
+	}
+	if w.Body.String() != want {
+		t.Errorf("handler body: %s", w.Body.String())
+	}
+}
+
+func TestValues(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "", "l")
+	g := r.NewGauge("g", "", "l")
+	h := r.NewHistogram("h", "", nil, "l")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc("x")
+				g.Add(0.5, "x")
+				h.Observe(0.01, "x")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := c.Value("x"); got != 1000 {
+		t.Errorf("counter: got(%v) != want(1000)", got)
+	}
+	if got := g.Value("x"); got != 500 {
+		t.Errorf("gauge: got(%v) != want(500)", got)
+	}
+	if n, _ := h.Count("x"); n != 1000 {
+		t.Errorf("histogram: got(%v) != want(1000)", n)
+	}
+	if got := c.Value("y"); got != 0 {
+		t.Errorf("missing counter: got(%v) != want(0)", got)
+	}
+	g.Delete("x")
+	if got := g.Value("x"); got != 0 {
+		t.Errorf("deleted gauge: got(%v) != want(0)", got)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []func(r *Registry){
+		func(r *Registry) { r.NewCounter("a-b", "") },
+		func(r *Registry) { r.NewCounter("c", ""); r.NewGauge("c", "") },
+		func(r *Registry) { r.NewHistogram("h", "", nil, "le") },
+		func(r *Registry) { r.NewCounter("c", "", "l").Inc() },
+		func(r *Registry) { r.NewCounter("c", "").Add(-1) },
+	}
+	for i, f := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("tests[%d]: no panic", i)
+				}
+			}()
+			f(NewRegistry())
+		}()
+	}
+}
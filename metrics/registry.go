@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Default is the registry of the package level functions, exposed by metrics-module.
+var Default = NewRegistry()
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+func NewGaugeFunc(name, help string, f func() float64) {
+	Default.NewGaugeFunc(name, help, f)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+func Handler() http.Handler {
+	return Default
+}
+
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]*vec
+	funcs   map[string]func() float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]*vec),
+		funcs:   make(map[string]func() float64),
+	}
+}
+
+// register adds the metric, it panics if the name is invalid or registered,
+// like expvar.Publish. The metric without labels is exposed from the beginning.
+func (r *Registry) register(v *vec) {
+	if !namePattern.MatchString(v.name) {
+		panic(fmt.Sprintf("metric(%s) invalid name", v.name))
+	}
+	for _, l := range v.labels {
+		if !namePattern.MatchString(l) || strings.HasPrefix(l, "__") || l == "le" {
+			panic(fmt.Sprintf("metric(%s) invalid label %q", v.name, l))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[v.name]; ok {
+		panic(fmt.Sprintf("metric(%s) duplicate", v.name))
+	}
+	r.metrics[v.name] = v
+	if v.create != nil && len(v.labels) == 0 {
+		v.get(nil)
+	}
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	v := newVec(name, help, "counter", labels, func() interface{} { return new(float) })
+	r.register(v)
+	return &Counter{vec: v}
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	v := newVec(name, help, "gauge", labels, func() interface{} { return new(float) })
+	r.register(v)
+	return &Gauge{vec: v}
+}
+
+// NewGaugeFunc adds a gauge without labels, the value of which is returned by f on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(newVec(name, help, "gauge", nil, nil))
+	r.mu.Lock()
+	r.funcs[name] = f
+	r.mu.Unlock()
+}
+
+// NewHistogram adds a histogram, the buckets of which are sorted upper bounds, DefBuckets if nil.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	v := newVec(name, help, "histogram", labels, func() interface{} {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	v.buckets = buckets
+	r.register(v)
+	return &Histogram{vec: v}
+}
+
+// WritePrometheus writes the metrics sorted by name in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		r.mu.RLock()
+		v, f := r.metrics[name], r.funcs[name]
+		r.mu.RUnlock()
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(v.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, v.typ)
+		if f != nil {
+			fmt.Fprintf(bw, "%s %s\n", name, formatFloat(f()))
+			continue
+		}
+		for _, s := range v.sorted() {
+			switch x := s.value.(type) {
+			case *float:
+				fmt.Fprintf(bw, "%s%s %s\n", name, formatLabels(v.labels, s.values, "", 0), formatFloat(x.load()))
+			case *histogram:
+				counts, sum, count := x.snapshot()
+				for i, le := range v.buckets {
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", name, formatLabels(v.labels, s.values, "le", le), counts[i])
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", name, formatLabels(v.labels, s.values, "le", math.Inf(1)), count)
+				fmt.Fprintf(bw, "%s_sum%s %s\n", name, formatLabels(v.labels, s.values, "", 0), formatFloat(sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", name, formatLabels(v.labels, s.values, "", 0), count)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WritePrometheus(w)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabels formats the label pairs, with the extra label if it is not empty.
+func formatLabels(labels, values []string, extra string, value float64) string {
+	if len(labels) == 0 && extra == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", l, escapeLabel(values[i]))
+	}
+	if extra != "" {
+		if len(labels) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extra, formatFloat(value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
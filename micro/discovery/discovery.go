@@ -49,6 +49,7 @@ func (d *Discovery) Watch(svc string, refreshs ...Refresh) (Service, error) {
 	s := newService(svc, refreshs...)
 	m := monitor{
 		client:  d.client,
+		service: svc,
 		prefix:  d.prefix(svc),
 		kvs:     make(map[string][]byte),
 		refresh: s.Refresh,
@@ -0,0 +1,10 @@
+package discovery
+
+import "github.com/ironzhang/matrix/metrics"
+
+var (
+	discoveredEndpoints = metrics.NewGauge("discovery_endpoints",
+		"The number of the endpoints of the watched services.", "service")
+	watchRestarts = metrics.NewCounter("discovery_watch_restarts_total",
+		"The number of the watches restarted after their channels were closed.", "service")
+)
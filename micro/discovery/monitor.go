@@ -13,6 +13,7 @@ import (
 type monitor struct {
 	client   *clientv3.Client
 	revision int64
+	service  string
 	prefix   string
 	kvs      map[string][]byte
 	refresh  func(kvs map[string][]byte)
@@ -63,6 +64,7 @@ func (m *monitor) Run(ctx context.Context) {
 		select {
 		case resp, ok := <-watchc:
 			if !ok {
+				watchRestarts.Inc(m.service)
 				watchc = m.Watch(ctx)
 				continue
 			}
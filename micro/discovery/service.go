@@ -52,6 +52,7 @@ func (s *service) Refresh(kvs map[string][]byte) {
 	}
 	sort.Strings(addrs)
 	s.SetAddrs(addrs)
+	discoveredEndpoints.Set(float64(len(addrs)), s.name)
 
 	s.refreshm.RLock()
 	defer s.refreshm.RUnlock()
@@ -76,4 +77,5 @@ func (s *service) AddRefreshs(refreshs []Refresh) {
 func (s *service) Unwatch() {
 	close(s.done)
 	<-s.ok
+	discoveredEndpoints.Delete(s.name)
 }
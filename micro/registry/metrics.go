@@ -0,0 +1,12 @@
+package registry
+
+import "github.com/ironzhang/matrix/metrics"
+
+var (
+	registeredEndpoints = metrics.NewGauge("registry_endpoints",
+		"The number of the endpoints registered by this process.", "service")
+	leaseRenewals = metrics.NewCounter("registry_lease_renewals_total",
+		"The number of the lease keep alive responses.", "service")
+	reregistrations = metrics.NewCounter("registry_reregistrations_total",
+		"The number of the endpoints registered again after their keys were lost.", "service")
+)
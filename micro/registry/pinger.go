@@ -9,11 +9,12 @@ import (
 	"github.com/ironzhang/matrix/tlog"
 )
 
-func newPinger(c *clientv3.Client, timeout time.Duration, ttl int64, key, value string) *pinger {
+func newPinger(c *clientv3.Client, timeout time.Duration, ttl int64, service, key, value string) *pinger {
 	return &pinger{
 		client:  c,
 		timeout: timeout,
 		ttl:     ttl,
+		service: service,
 		key:     key,
 		value:   value,
 		done:    make(chan struct{}),
@@ -25,6 +26,7 @@ type pinger struct {
 	client  *clientv3.Client
 	timeout time.Duration
 	ttl     int64
+	service string
 	key     string
 	value   string
 
@@ -129,6 +131,7 @@ func (p *pinger) Run(ctx context.Context) {
 				log.Errorw("put", "error", err)
 				continue
 			}
+			reregistrations.Inc(p.service)
 			log.Debug("ping")
 		case <-ctx.Done():
 			log.Debug("pinger quit")
@@ -145,14 +148,23 @@ func (p *pinger) Grant(ctx context.Context) error {
 	}
 
 	// keep alive, note: don't with timeout
-	if _, err = p.client.KeepAlive(context.Background(), resp.ID); err != nil {
+	ch, err := p.client.KeepAlive(context.Background(), resp.ID)
+	if err != nil {
 		return err
 	}
+	go p.countRenewals(ch)
 
 	p.leaseID = resp.ID
 	return nil
 }
 
+// countRenewals consumes the keep alive responses until the lease is revoked or expired.
+func (p *pinger) countRenewals(ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	for range ch {
+		leaseRenewals.Inc(p.service)
+	}
+}
+
 func (p *pinger) Revoke(ctx context.Context) error {
 	if _, err := p.client.Revoke(p.WithTimeout(ctx), p.leaseID); err != nil {
 		return err
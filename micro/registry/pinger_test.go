@@ -21,7 +21,7 @@ func NewClient(t *testing.T) *clientv3.Client {
 
 func TestPinger(t *testing.T) {
 	c := NewClient(t)
-	p := newPinger(c, 2*time.Second, 5, "TestPinger/Key", "1")
+	p := newPinger(c, 2*time.Second, 5, "TestPinger", "TestPinger/Key", "1")
 
 	var err error
 	var exist bool
@@ -55,11 +55,12 @@ func (r *Registry) Register(point Endpoint) error {
 		return fmt.Errorf("key(%s) existed", key)
 	}
 
-	p := newPinger(r.client, r.timeout, r.ttl, key, "1")
+	p := newPinger(r.client, r.timeout, r.ttl, point.Service, key, "1")
 	if err := p.Setup(); err != nil {
 		return err
 	}
 	r.pingers[key] = p
+	registeredEndpoints.Inc(point.Service)
 
 	return nil
 }
@@ -71,6 +72,7 @@ func (r *Registry) Unregister(point Endpoint) (err error) {
 	if p, ok := r.pingers[key]; ok {
 		err = p.Close()
 		delete(r.pingers, key)
+		registeredEndpoints.Dec(point.Service)
 	}
 	return err
 }
@@ -80,6 +82,7 @@ func (r *Registry) UnregisterAll() {
 	defer r.mu.Unlock()
 	for _, p := range r.pingers {
 		p.Close()
+		registeredEndpoints.Dec(p.service)
 	}
 	r.pingers = make(map[string]*pinger)
 }
@@ -1,21 +1,31 @@
 package gorpc
 
 import (
+	"context"
 	"errors"
 	"io"
+	"net"
 	"net/rpc"
 	"sync"
+
+	"github.com/ironzhang/matrix/tracing"
 )
 
-func Dial(net, addr string) (*Client, error) {
-	c := &Client{calls: make(chan *rpc.Call, 20)}
-	go calling(net, addr, c.calls)
+func Dial(network, addr string) (*Client, error) {
+	c := &Client{addr: addr, calls: make(chan *call, 20)}
+	go calling(network, addr, c.calls)
 	return c, nil
 }
 
 type Client struct {
+	addr  string
 	mu    sync.RWMutex
-	calls chan *rpc.Call
+	calls chan *call
+}
+
+type call struct {
+	*rpc.Call
+	trace tracing.SpanContext
 }
 
 func (c *Client) Close() error {
@@ -29,51 +39,77 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) Call(serviceMethod string, args interface{}, reply interface{}) error {
-	call := <-c.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1)).Done
-	return call.Error
+	return c.CallContext(context.Background(), serviceMethod, args, reply)
+}
+
+// CallContext calls the method in a client span, the child of the span of ctx if any.
+// The trace context is sent to the server, which starts the server spans if it serves
+// the connections with NewServerCodec. ctx does not cancel the call.
+func (c *Client) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
+	_, span := tracing.Start(ctx, serviceMethod, tracing.Client)
+	span.SetAttribute("rpc.system", "gorpc")
+	span.SetAttribute("rpc.method", serviceMethod)
+	span.SetAttribute("net.peer.name", c.addr)
+	done := c.send(serviceMethod, args, reply, make(chan *rpc.Call, 1), span.SpanContext()).Done
+	err := (<-done).Error
+	span.SetError(err)
+	span.End()
+	return err
 }
 
 func (c *Client) Go(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
+	return c.send(serviceMethod, args, reply, done, tracing.SpanContext{})
+}
+
+func (c *Client) send(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call, trace tracing.SpanContext) *rpc.Call {
 	if done == nil {
 		done = make(chan *rpc.Call, 1)
 	}
-	call := &rpc.Call{
-		ServiceMethod: serviceMethod,
-		Args:          args,
-		Reply:         reply,
-		Done:          done,
+	x := &call{
+		Call: &rpc.Call{
+			ServiceMethod: serviceMethod,
+			Args:          args,
+			Reply:         reply,
+			Done:          done,
+		},
+		trace: trace,
 	}
 
 	c.mu.RLock()
 	if c.calls != nil {
-		c.calls <- call
+		c.calls <- x
 	} else {
 		go func() {
-			call.Error = errors.New("client is closed")
-			call.Done <- call
+			x.Error = errors.New("client is closed")
+			x.Done <- x.Call
 		}()
 	}
 	c.mu.RUnlock()
 
-	return call
+	return x.Call
 }
 
-func calling(net, addr string, calls <-chan *rpc.Call) {
+func calling(network, addr string, calls <-chan *call) {
 	var err error
+	var codec *clientCodec
 	var client *rpc.Client
 	for call := range calls {
 		if client == nil {
-			if client, err = rpc.Dial(net, addr); err != nil {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
 				call.Error = err
-				call.Done <- call
+				call.Done <- call.Call
 				continue
 			}
+			codec = newClientCodec(conn)
+			client = rpc.NewClientWithCodec(codec)
 		}
+		codec.setTrace(call.trace)
 		if err = client.Call(call.ServiceMethod, call.Args, call.Reply); err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF {
 			client = nil
 		}
 		call.Error = err
-		call.Done <- call
+		call.Done <- call.Call
 	}
 	if client != nil {
 		client.Close()
@@ -1,9 +1,13 @@
 package gorpc
 
 import (
+	"context"
 	"net"
 	"net/rpc"
+	"sync"
 	"testing"
+
+	"github.com/ironzhang/matrix/tracing"
 )
 
 type Args struct {
@@ -96,3 +100,75 @@ func TestClientWithoutServer(t *testing.T) {
 		t.Logf("%d == ArithAdd(%d, %d)", got, a, b)
 	}
 }
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *tracing.SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+func (r *spanRecorder) kind(kind string) []*tracing.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var spans []*tracing.SpanData
+	for _, s := range r.spans {
+		if s.Kind == kind {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func TestClientCallContext(t *testing.T) {
+	network, address := "tcp", "localhost:2002"
+	svr, err := NewArithServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go Accept(svr, ln)
+
+	c, err := Dial(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := &spanRecorder{}
+	tracing.SetExporter(r)
+	defer tracing.SetExporter(nil)
+
+	ctx, root := tracing.Start(context.Background(), "root", tracing.Internal)
+	var result int
+	if err = c.CallContext(ctx, "Arith.Add", &Args{A: 1, B: 2}, &result); err != nil {
+		t.Fatalf("call context: %v", err)
+	}
+	if err = c.CallContext(ctx, "Arith.Sub", &Args{A: 1, B: 2}, &result); err == nil {
+		t.Fatalf("call unknown method succeeded")
+	}
+
+	clients, servers := r.kind("client"), r.kind("server")
+	if len(clients) != 2 || len(servers) != 2 {
+		t.Fatalf("spans: got(%d, %d) != want(2, 2)", len(clients), len(servers))
+	}
+	for i, s := range clients {
+		if s.ParentID != root.SpanContext().SpanID.String() || s.Attributes["net.peer.name"] != address {
+			t.Errorf("clients[%d]: %+v", i, s)
+		}
+		if servers[i].TraceID != s.TraceID || servers[i].ParentID != s.SpanID || servers[i].Name != s.Name {
+			t.Errorf("servers[%d]: %+v", i, servers[i])
+		}
+	}
+	if clients[0].Name != "Arith.Add" || clients[0].Error != "" || clients[1].Error == "" || servers[1].Error == "" {
+		t.Errorf("spans: %+v, %+v", clients, servers)
+	}
+}
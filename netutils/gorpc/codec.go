@@ -0,0 +1,178 @@
+package gorpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/ironzhang/matrix/tlog"
+	"github.com/ironzhang/matrix/tracing"
+)
+
+// request is the request header of rpc.Request with the trace context. The gob decoders
+// ignore the fields missing in the decoded type, so the codecs work with the gob codecs
+// of net/rpc, in which case the trace context is not carried.
+type request struct {
+	ServiceMethod string
+	Seq           uint64
+	Traceparent   string
+	Tracestate    string
+}
+
+type clientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	trace  tracing.SpanContext
+}
+
+func newClientCodec(conn io.ReadWriteCloser) *clientCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &clientCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+	}
+}
+
+// setTrace sets the trace context of the next request, the requests are written
+// one by one by the calling goroutine.
+func (c *clientCodec) setTrace(sc tracing.SpanContext) {
+	c.trace = sc
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) (err error) {
+	h := request{ServiceMethod: r.ServiceMethod, Seq: r.Seq}
+	if c.trace.IsValid() {
+		h.Traceparent = c.trace.Traceparent()
+		h.Tracestate = c.trace.TraceState
+	}
+	if err = c.enc.Encode(&h); err != nil {
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *clientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+type serverCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+
+	mu    sync.Mutex
+	spans map[uint64]*tracing.Span
+}
+
+// NewServerCodec returns a gob server codec, which starts a server span for every
+// request, the child of the client span if the request carries the trace context.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &serverCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+		spans:  make(map[uint64]*tracing.Span),
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	var h request
+	if err := c.dec.Decode(&h); err != nil {
+		return err
+	}
+	r.ServiceMethod, r.Seq = h.ServiceMethod, h.Seq
+
+	ctx := context.Background()
+	if sc, err := tracing.ParseTraceparent(h.Traceparent); err == nil {
+		sc.TraceState = h.Tracestate
+		ctx = tracing.ContextWithRemote(ctx, sc)
+	}
+	_, span := tracing.Start(ctx, h.ServiceMethod, tracing.Server)
+	span.SetAttribute("rpc.system", "gorpc")
+	span.SetAttribute("rpc.method", h.ServiceMethod)
+	c.mu.Lock()
+	c.spans[h.Seq] = span
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	c.mu.Lock()
+	span := c.spans[r.Seq]
+	delete(c.spans, r.Seq)
+	c.mu.Unlock()
+	if span != nil {
+		if r.Error != "" {
+			span.SetError(errors.New(r.Error))
+		}
+		span.End()
+	}
+
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// gob couldn't encode the header, shut down
+			tlog.Std().Sugar().Errorw("encode response header", "error", err)
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			// was a gob problem encoding the body but the header has been written,
+			// shut down the connection to signal that the connection is broken
+			tlog.Std().Sugar().Errorw("encode response body", "error", err)
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	if c.closed {
+		// only call c.rwc.Close once, otherwise the semantics are undefined
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
+
+// Accept serves the connections of ln with the server codec, until ln is closed.
+func Accept(server *rpc.Server, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			tlog.Std().Sugar().Debugw("accept", "error", err)
+			return
+		}
+		go server.ServeCodec(NewServerCodec(conn))
+	}
+}
@@ -21,6 +21,7 @@ func Listen(network string, addrs []string, backlog int) (net.Listener, error) {
 	for _, addr := range addrs {
 		ln, err := net.Listen(network, addr)
 		if err != nil {
+			closeAll(listeners)
 			return nil, err
 		}
 		listeners = append(listeners, ln)
@@ -33,6 +34,7 @@ func ListenTLS(network string, addrs []string, backlog int, config *tls.Config)
 	for _, addr := range addrs {
 		ln, err := tls.Listen(network, addr, config)
 		if err != nil {
+			closeAll(listeners)
 			return nil, err
 		}
 		listeners = append(listeners, ln)
@@ -40,6 +42,12 @@ func ListenTLS(network string, addrs []string, backlog int, config *tls.Config)
 	return NewListener(network, listeners, backlog), nil
 }
 
+func closeAll(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
 func NewListener(network string, listeners []net.Listener, backlog int) net.Listener {
 	if len(listeners) == 1 {
 		return listeners[0]
@@ -11,7 +11,7 @@ import (
 	"github.com/ironzhang/matrix/httputils"
 	"github.com/ironzhang/matrix/restful/codec"
 	"github.com/ironzhang/matrix/tlog"
-	"github.com/ironzhang/matrix/uuid"
+	"github.com/ironzhang/matrix/tracing"
 )
 
 var DefaultClient = &Client{
@@ -88,7 +88,13 @@ func (c *Client) PutContext(ctx context.Context, url string, args, reply interfa
 }
 
 func (c *Client) DoContext(ctx context.Context, method, url string, args, reply interface{}) (err error) {
-	ctx = contextWithTraceId(ctx)
+	ctx, span := tracing.Start(ctx, method, tracing.Client)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("http.url", url)
 	log := tlog.WithContext(ctx).Sugar().With("method", method, "url", url)
 
 	var b bytes.Buffer
@@ -110,12 +116,16 @@ func (c *Client) DoContext(ctx context.Context, method, url string, args, reply
 	c.setHeader(ctx, req.Header)
 
 	// Do
+	start := time.Now()
 	resp, err := c.client().Do(req)
 	if err != nil {
+		observeClient(req.URL.Host, method, 0, time.Since(start))
 		log.Errorw("client do", "error", err)
 		return err
 	}
 	defer resp.Body.Close()
+	observeClient(req.URL.Host, method, resp.StatusCode, time.Since(start))
+	span.SetAttribute("http.status_code", resp.StatusCode)
 
 	// Handle error
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -140,9 +150,10 @@ func (c *Client) DoContext(ctx context.Context, method, url string, args, reply
 
 func (c *Client) setHeader(ctx context.Context, h http.Header) {
 	h.Set("Content-Type", c.codec().ContentType())
-	if v := context_value.ParseTraceId(ctx); v != "" {
-		h.Set(httputils.X_TRACE_ID, v)
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		h.Set(httputils.X_TRACE_ID, sc.TraceID.String())
 	}
+	tracing.Inject(ctx, h)
 	if v := context_value.ParseVerbose(ctx); v {
 		h.Set(httputils.X_VERBOSE, "1")
 	}
@@ -168,10 +179,3 @@ func (c *Client) context() context.Context {
 	}
 	return c.Context
 }
-
-func contextWithTraceId(ctx context.Context) context.Context {
-	if v := context_value.ParseTraceId(ctx); v == "" {
-		return context_value.WithTraceId(ctx, uuid.New().String())
-	}
-	return ctx
-}
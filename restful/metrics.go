@@ -0,0 +1,77 @@
+package restful
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ironzhang/matrix/metrics"
+)
+
+var (
+	serverRequests = metrics.NewCounter("restful_server_requests_total",
+		"The number of the requests served by restful.ServeMux.", "method", "pattern", "status")
+	serverLatency = metrics.NewHistogram("restful_server_request_duration_seconds",
+		"The latency of the requests served by restful.ServeMux.", nil, "method", "pattern")
+	clientRequests = metrics.NewCounter("restful_client_requests_total",
+		"The number of the requests sent by restful.Client, the status is error if no response.", "host", "method", "status")
+	clientLatency = metrics.NewHistogram("restful_client_request_duration_seconds",
+		"The latency of the requests sent by restful.Client.", nil, "host", "method")
+)
+
+// unmatched is the pattern label of the requests which match no pattern,
+// so the unknown paths do not blow up the series.
+const unmatched = "unmatched"
+
+func observeServer(method, pat string, status int, d time.Duration) {
+	if pat == "" {
+		pat = unmatched
+	}
+	method = strings.ToUpper(method)
+	serverRequests.Inc(method, pat, strconv.Itoa(status))
+	serverLatency.Observe(d.Seconds(), method, pat)
+}
+
+func observeClient(host, method string, status int, d time.Duration) {
+	s := "error"
+	if status > 0 {
+		s = strconv.Itoa(status)
+	}
+	method = strings.ToUpper(method)
+	clientRequests.Inc(host, method, s)
+	clientLatency.Observe(d.Seconds(), host, method)
+}
+
+// statusWriter records the status code written to the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
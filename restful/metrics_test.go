@@ -0,0 +1,60 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ironzhang/matrix/tlog"
+)
+
+func TestMetrics(t *testing.T) {
+	tlog.Init(tlog.Config{DisableStderr: true})
+
+	m, err := NewArithServeMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(m)
+	defer s.Close()
+	host := s.Listener.Addr().String()
+
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+		status  string
+	}{
+		{method: "POST", path: "/add", pattern: "/add", status: "200"},
+		{method: "post", path: "/div", pattern: "/div", status: "200"},
+		{method: "GET", path: "/add", pattern: unmatched, status: "405"},
+		{method: "POST", path: "/none", pattern: unmatched, status: "404"},
+	}
+	for i, tt := range tests {
+		var reply Reply
+		method := strings.ToUpper(tt.method)
+		srv := serverRequests.Value(method, tt.pattern, tt.status)
+		cli := clientRequests.Value(host, method, tt.status)
+		n, _ := serverLatency.Count(method, tt.pattern)
+		DefaultClient.Do(tt.method, s.URL+tt.path, Args{A: 1, B: 1}, &reply)
+		if got := serverRequests.Value(method, tt.pattern, tt.status); got != srv+1 {
+			t.Errorf("tests[%d]: server requests: got(%v) != want(%v)", i, got, srv+1)
+		}
+		if got, _ := serverLatency.Count(method, tt.pattern); got != n+1 {
+			t.Errorf("tests[%d]: server latency count: got(%v) != want(%v)", i, got, n+1)
+		}
+		if got := clientRequests.Value(host, method, tt.status); got != cli+1 {
+			t.Errorf("tests[%d]: client requests: got(%v) != want(%v)", i, got, cli+1)
+		}
+	}
+
+	u, _ := url.Parse(s.URL)
+	s.Close()
+	before := clientRequests.Value(u.Host, http.MethodGet, "error")
+	DefaultClient.Get(s.URL+"/add", nil, nil)
+	if got := clientRequests.Value(u.Host, http.MethodGet, "error"); got != before+1 {
+		t.Errorf("client errors: got(%v) != want(%v)", got, before+1)
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/ironzhang/matrix/codes"
 	"github.com/ironzhang/matrix/context-value"
 	"github.com/ironzhang/matrix/httputils"
 	"github.com/ironzhang/matrix/restful/codec"
 	"github.com/ironzhang/matrix/tlog"
-	"github.com/ironzhang/matrix/uuid"
+	"github.com/ironzhang/matrix/tracing"
 )
 
 func NewServeMux(c codec.Codec) *ServeMux {
@@ -76,13 +77,27 @@ func (m *ServeMux) Add(meth, pat string, i interface{}) error {
 }
 
 func (m *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := context_value.WithTraceId(context.Background(), getTraceId(r.Header))
-	if err := m.serveHTTP(ctx, w, r); err != nil {
-		m.setError(w, err)
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	ctx, span := tracing.Start(requestContext(r), r.Method, tracing.Server)
+	pat, err := m.serveHTTP(ctx, sw, r)
+	if err != nil {
+		m.setError(sw, err)
+		span.SetError(err)
+	}
+	if pat != "" {
+		span.SetName(r.Method + " " + pat)
+		span.SetAttribute("http.route", pat)
 	}
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("http.status_code", sw.code())
+	span.End()
+	observeServer(r.Method, pat, sw.code(), time.Since(start))
 }
 
-func (m *ServeMux) serveHTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+// serveHTTP serves the request, and returns the matched pattern.
+func (m *ServeMux) serveHTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, error) {
 	log := tlog.WithContext(ctx).Sugar().With("method", r.Method, "path", r.URL.Path)
 
 	var found bool
@@ -96,15 +111,15 @@ func (m *ServeMux) serveHTTP(ctx context.Context, w http.ResponseWriter, r *http
 		if !ok {
 			continue
 		}
-		return m.serve(ctx, h, v, w, r)
+		return p.pat, m.serve(ctx, h, v, w, r)
 	}
 
 	if found {
 		log.Info(http.StatusText(http.StatusMethodNotAllowed))
-		return Errorf(http.StatusMethodNotAllowed, codes.NotAllowed, "method(%s) not allowed", r.Method)
+		return "", Errorf(http.StatusMethodNotAllowed, codes.NotAllowed, "method(%s) not allowed", r.Method)
 	} else {
 		log.Info(http.StatusText(http.StatusNotFound))
-		return Errorf(http.StatusNotFound, codes.NotFound, "page(%s) not found", r.URL.Path)
+		return "", Errorf(http.StatusNotFound, codes.NotFound, "page(%s) not found", r.URL.Path)
 	}
 }
 
@@ -168,9 +183,12 @@ func (m *ServeMux) setError(w http.ResponseWriter, err error) {
 	m.codec.EncodeError(w, e)
 }
 
-func getTraceId(h http.Header) string {
-	if v := h.Get(httputils.X_TRACE_ID); v != "" {
-		return v
+// requestContext returns a context with the trace of the request. The X-Trace-Id
+// header is the trace id of the requests from the clients without traceparent.
+func requestContext(r *http.Request) context.Context {
+	ctx := tracing.Extract(context.Background(), r.Header)
+	if v := r.Header.Get(httputils.X_TRACE_ID); v != "" {
+		ctx = context_value.WithTraceId(ctx, v)
 	}
-	return uuid.New().String()
+	return ctx
 }
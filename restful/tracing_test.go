@@ -0,0 +1,95 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ironzhang/matrix/context-value"
+	"github.com/ironzhang/matrix/httputils"
+	"github.com/ironzhang/matrix/tlog"
+	"github.com/ironzhang/matrix/tracing"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *tracing.SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+func (r *spanRecorder) find(kind string) *tracing.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.Kind == kind {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	tlog.Init(tlog.Config{DisableStderr: true})
+
+	r := &spanRecorder{}
+	tracing.SetExporter(r)
+	defer tracing.SetExporter(nil)
+
+	m, err := NewArithServeMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(m)
+	defer s.Close()
+
+	ctx, root := tracing.Start(context.Background(), "root", tracing.Internal)
+	var reply Reply
+	if err = DefaultClient.PostContext(ctx, s.URL+"/div", Args{A: 1, B: 0}, &reply); err == nil {
+		t.Fatalf("divide by zero succeeded")
+	}
+	root.End()
+
+	client, server := r.find("client"), r.find("server")
+	if client == nil || server == nil {
+		t.Fatalf("spans: %v", r.spans)
+	}
+	if client.TraceID != root.SpanContext().TraceID.String() || client.ParentID != root.SpanContext().SpanID.String() {
+		t.Errorf("client span: %+v", client)
+	}
+	if server.TraceID != client.TraceID || server.ParentID != client.SpanID {
+		t.Errorf("server span: %+v", server)
+	}
+	if server.Name != "POST /div" || server.Attributes["http.status_code"] != 500 || server.Error == "" {
+		t.Errorf("server span: %+v", server)
+	}
+	if client.Attributes["http.status_code"] != 500 || client.Error == "" {
+		t.Errorf("client span: %+v", client)
+	}
+}
+
+func TestTraceIdHeader(t *testing.T) {
+	var h http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h = r.Header
+	}))
+	defer s.Close()
+
+	ctx := context_value.WithTraceId(context.Background(), "legacy")
+	if err := DefaultClient.GetContext(ctx, s.URL, nil, nil); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	sc, err := tracing.ParseTraceparent(h.Get(tracing.TraceparentHeader))
+	if err != nil {
+		t.Fatalf("parse traceparent: %v", err)
+	}
+	if got, want := h.Get(httputils.X_TRACE_ID), sc.TraceID.String(); got != want {
+		t.Errorf("trace id: got(%s) != want(%s)", got, want)
+	}
+}
@@ -83,16 +83,26 @@ func Reset() error {
 	return nil
 }
 
-func Init(cfg Config) (*zap.Logger, error) {
+// New builds a logger of cfg and its level, without installing it as the std logger.
+func New(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
 	sink, err := cfg.openSinks()
 	if err != nil {
-		return nil, err
+		return nil, zap.AtomicLevel{}, err
 	}
-	level = zap.NewAtomicLevelAt(cfg.Level)
+	lvl := zap.NewAtomicLevelAt(cfg.Level)
 	opts := cfg.buildOptions(sink)
 	enc := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
-	core := zapcore.NewCore(enc, sink, level)
-	std = zap.New(core, opts...)
+	core := zapcore.NewCore(enc, sink, lvl)
+	return zap.New(core, opts...), lvl, nil
+}
+
+func Init(cfg Config) (*zap.Logger, error) {
+	logger, lvl, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	level = lvl
+	std = logger
 	sugar = std.Sugar()
 	return std, nil
 }
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/matrix/tlog"
+)
+
+// SpanData is an ended span passed to the exporter.
+type SpanData struct {
+	TraceID    string                 `json:"traceId"`
+	SpanID     string                 `json:"spanId"`
+	ParentID   string                 `json:"parentId,omitempty"`
+	TraceState string                 `json:"traceState,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Duration   float64                `json:"duration"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Exporter exports the ended spans, it is called by the goroutines ending the spans
+// and must not block them for long.
+type Exporter interface {
+	ExportSpan(s *SpanData)
+}
+
+var exporter struct {
+	sync.RWMutex
+	e Exporter
+}
+
+// SetExporter sets the exporter of the sampled spans, nil disables the exporting.
+func SetExporter(e Exporter) {
+	exporter.Lock()
+	exporter.e = e
+	exporter.Unlock()
+}
+
+func export(s *SpanData) {
+	exporter.RLock()
+	e := exporter.e
+	exporter.RUnlock()
+	if e != nil {
+		e.ExportSpan(s)
+	}
+}
+
+// JSONExporter writes the spans as JSON, one span per line.
+type JSONExporter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter returns a JSONExporter appending to the file.
+func NewFileExporter(file string) (*JSONExporter, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONExporter(f), nil
+}
+
+func (e *JSONExporter) ExportSpan(s *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(s); err != nil {
+		tlog.Std().Sugar().Errorw("export span", "traceId", s.TraceID, "spanId", s.SpanID, "error", err)
+	}
+}
+
+// Close closes the writer if it is an io.Closer.
+func (e *JSONExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
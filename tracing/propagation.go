@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The W3C Trace Context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses the traceparent header, version-traceid-parentid-flags.
+// The unknown future versions are parsed as version 00, ignoring the extra fields.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, errInvalidTraceparent
+	}
+	version, err := decodeHex(s[0:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(s) != 55) {
+		return sc, errInvalidTraceparent
+	}
+	traceID, err := decodeHex(s[3:35])
+	if err != nil {
+		return sc, errInvalidTraceparent
+	}
+	spanID, err := decodeHex(s[36:52])
+	if err != nil {
+		return sc, errInvalidTraceparent
+	}
+	flags, err := decodeHex(s[53:55])
+	if err != nil {
+		return sc, errInvalidTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes the lowercase hex string, the uppercase is invalid in traceparent.
+func decodeHex(s string) ([]byte, error) {
+	if strings.ToLower(s) != s {
+		return nil, fmt.Errorf("uppercase hex %q", s)
+	}
+	return hex.DecodeString(s)
+}
+
+// parseTraceID parses the trace id of the logs, 32 hex digits or a uuid.
+func parseTraceID(s string) (TraceID, bool) {
+	var id TraceID
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != len(id) {
+		return id, false
+	}
+	copy(id[:], b)
+	return id, id.IsValid()
+}
+
+// Traceparent formats the span context as the version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// Extract returns a context with the remote span context of the traceparent and
+// tracestate headers, or ctx if traceparent is missing or invalid.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(strings.TrimSpace(h.Get(TraceparentHeader)))
+	if err != nil {
+		return ctx
+	}
+	var states []string
+	for _, v := range h[http.CanonicalHeaderKey(TracestateHeader)] {
+		if v = strings.TrimSpace(v); v != "" {
+			states = append(states, v)
+		}
+	}
+	sc.TraceState = strings.Join(states, ",")
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject sets the traceparent and tracestate headers of the span context of ctx, if any.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	}
+}
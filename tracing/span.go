@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/matrix/context-value"
+)
+
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+const FlagSampled byte = 0x01
+
+// SpanContext is the part of a span propagated across the processes.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+type SpanKind int
+
+const (
+	Internal SpanKind = iota
+	Server
+	Client
+)
+
+var kindNames = map[SpanKind]string{
+	Internal: "internal",
+	Server:   "server",
+	Client:   "client",
+}
+
+func (k SpanKind) String() string {
+	return kindNames[k]
+}
+
+type Span struct {
+	sc     SpanContext
+	parent SpanID
+	kind   SpanKind
+	start  time.Time
+
+	mu    sync.Mutex
+	name  string
+	attrs map[string]interface{}
+	err   string
+	ended bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemote returns a context with the span context received from another process,
+// which is the parent of the spans started with the context.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span, or the remote span context.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := FromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a span, the child of the span of ctx if any, otherwise the root of a
+// new sampled trace. The trace id of the logs in ctx, e.g. from a legacy X-Trace-Id
+// header, is taken as the id of the new trace if it is 32 hex digits or a uuid. The
+// trace id of the span is set to ctx for the logs, so the logs and the spans share it.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	s := &Span{
+		kind:  kind,
+		start: time.Now(),
+		name:  name,
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Flags = parent.Flags
+		s.sc.TraceState = parent.TraceState
+		s.parent = parent.SpanID
+	} else {
+		if id, ok := parseTraceID(context_value.ParseTraceId(ctx)); ok {
+			s.sc.TraceID = id
+		} else {
+			rand.Read(s.sc.TraceID[:])
+		}
+		s.sc.Flags = FlagSampled
+	}
+	rand.Read(s.sc.SpanID[:])
+
+	if id := s.sc.TraceID.String(); context_value.ParseTraceId(ctx) != id {
+		ctx = context_value.WithTraceId(ctx, id)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute sets an attribute, it is ignored after End.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{})
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span failed, a nil err is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends the span and exports it if it is sampled, the later calls are ignored.
+func (s *Span) End() {
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	d := &SpanData{
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		TraceState: s.sc.TraceState,
+		Name:       s.name,
+		Kind:       s.kind.String(),
+		Start:      s.start,
+		End:        end,
+		Duration:   end.Sub(s.start).Seconds(),
+		Attributes: s.attrs,
+		Error:      s.err,
+	}
+	if s.parent.IsValid() {
+		d.ParentID = s.parent.String()
+	}
+	s.mu.Unlock()
+
+	if s.sc.IsSampled() {
+		export(d)
+	}
+}
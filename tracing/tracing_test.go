@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ironzhang/matrix/context-value"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (r *recorder) ExportSpan(s *SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		s       string
+		trace   string
+		span    string
+		sampled bool
+		err     bool
+	}{
+		{s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7", sampled: true},
+		{s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7"},
+		{s: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", trace: "4bf92f3577b34da6a3ce929d0e0e4736", span: "00f067aa0ba902b7", sampled: true},
+		{s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", err: true},
+		{s: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", err: true},
+		{s: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", err: true},
+		{s: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", err: true},
+		{s: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", err: true},
+		{s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", err: true},
+		{s: "", err: true},
+	}
+	for i, tt := range tests {
+		sc, err := ParseTraceparent(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("tests[%d]: error: %v", i, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sc.TraceID.String() != tt.trace || sc.SpanID.String() != tt.span || sc.IsSampled() != tt.sampled {
+			t.Errorf("tests[%d]: got(%s, %s, %v) != want(%s, %s, %v)", i, sc.TraceID, sc.SpanID, sc.IsSampled(), tt.trace, tt.span, tt.sampled)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	r := &recorder{}
+	SetExporter(r)
+	defer SetExporter(nil)
+
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Add(TracestateHeader, "congo=t61rcWkgMzE")
+	in.Add(TracestateHeader, "rojo=00f067aa0ba902b7")
+
+	ctx, server := Start(Extract(context.Background(), in), "server", Server)
+	if got, want := context_value.ParseTraceId(ctx), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("log trace id: got(%s) != want(%s)", got, want)
+	}
+	cctx, client := Start(ctx, "client", Client)
+	out := http.Header{}
+	Inject(cctx, out)
+	if got, want := out.Get(TraceparentHeader), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+client.SpanContext().SpanID.String()+"-01"; got != want {
+		t.Errorf("traceparent: got(%s) != want(%s)", got, want)
+	}
+	if got, want := out.Get(TracestateHeader), "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"; got != want {
+		t.Errorf("tracestate: got(%s) != want(%s)", got, want)
+	}
+	client.SetError(errors.New("refused"))
+	client.End()
+	server.End()
+	server.End()
+
+	if len(r.spans) != 2 {
+		t.Fatalf("spans: got(%d) != want(2)", len(r.spans))
+	}
+	c, s := r.spans[0], r.spans[1]
+	if s.ParentID != "00f067aa0ba902b7" || c.ParentID != s.SpanID || c.TraceID != s.TraceID {
+		t.Errorf("parents: server(%s/%s) client(%s/%s)", s.SpanID, s.ParentID, c.SpanID, c.ParentID)
+	}
+	if c.Kind != "client" || s.Kind != "server" || c.Error != "refused" {
+		t.Errorf("client span: %+v", c)
+	}
+
+	// not sampled spans are not exported
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := Start(Extract(context.Background(), in), "server", Server)
+	span.End()
+	if len(r.spans) != 2 {
+		t.Errorf("not sampled span exported")
+	}
+}
+
+func TestNewTrace(t *testing.T) {
+	ctx, root := Start(context.Background(), "root", Internal)
+	_, child := Start(ctx, "child", Internal)
+	if !root.SpanContext().IsValid() || !root.SpanContext().IsSampled() {
+		t.Errorf("root span context: %+v", root.SpanContext())
+	}
+	if child.SpanContext().TraceID != root.SpanContext().TraceID || child.parent != root.SpanContext().SpanID {
+		t.Errorf("child span context: %+v", child.SpanContext())
+	}
+
+	h := http.Header{}
+	Inject(context.Background(), h)
+	if len(h) != 0 {
+		t.Errorf("inject without span: %v", h)
+	}
+}
+
+func TestLegacyTraceID(t *testing.T) {
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tests := []struct {
+		legacy string
+		parent bool
+		trace  string
+	}{
+		{legacy: "4bf92f3577b34da6a3ce929d0e0e4736", trace: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{legacy: "0f8fad5b-d9cb-469f-a165-70867728950e", trace: "0f8fad5bd9cb469fa16570867728950e"},
+		{legacy: "0f8fad5b-d9cb-469f-a165-70867728950e", parent: true, trace: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{legacy: "not-a-trace-id"},
+		{legacy: "00000000000000000000000000000000"},
+	}
+	for i, tt := range tests {
+		ctx := context_value.WithTraceId(context.Background(), tt.legacy)
+		if tt.parent {
+			ctx = ContextWithRemote(ctx, parent)
+		}
+		ctx, span := Start(ctx, "server", Server)
+		trace := span.SpanContext().TraceID.String()
+		if tt.trace != "" && trace != tt.trace {
+			t.Errorf("tests[%d]: trace id: got(%s) != want(%s)", i, trace, tt.trace)
+		}
+		if got := context_value.ParseTraceId(ctx); got != trace {
+			t.Errorf("tests[%d]: log trace id: got(%s) != want(%s)", i, got, trace)
+		}
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	var b bytes.Buffer
+	SetExporter(NewJSONExporter(&b))
+	defer SetExporter(nil)
+
+	_, span := Start(context.Background(), "GET /users/:id", Server)
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+	span.SetAttribute("ignored", true)
+
+	var d SpanData
+	if err := json.Unmarshal(b.Bytes(), &d); err != nil {
+		t.Fatalf("unmarshal %s: %v", b.String(), err)
+	}
+	if d.Name != "GET /users/:id" || d.TraceID != span.SpanContext().TraceID.String() || d.Attributes["http.status_code"] != float64(200) || len(d.Attributes) != 1 {
+		t.Errorf("span data: %+v", d)
+	}
+}